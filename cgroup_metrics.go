@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"io/fs"
+	"os"
+)
+
+// CgroupCollectorOption configures a [NewCgroupCollector].
+type CgroupCollectorOption func(*cgroupCollector)
+
+// WithCgroupFS reads cgroup files from fsys instead of the root filesystem.
+// fsys is expected to contain proc/self/cgroup and sys/fs/cgroup, and is
+// primarily useful for testing.
+func WithCgroupFS(fsys fs.FS) CgroupCollectorOption {
+	return func(c *cgroupCollector) { c.fsys = fsys }
+}
+
+type cgroupCollector struct {
+	fsys fs.FS
+}
+
+// NewCgroupCollector is a [MetricCollector] that yields resource usage and limits of
+// the cgroup the process runs in, such as a container. Both cgroup v2 and v1
+// are supported. Metrics are prefixed with `cgroup_`:
+//
+//   - cgroup_memory_usage_bytes
+//   - cgroup_memory_limit_bytes
+//   - cgroup_memory_oom_events_total (v2 only)
+//   - cgroup_memory_oom_kills_total
+//   - cgroup_cpu_limit_cores
+//   - cgroup_cpu_usage_seconds_total
+//   - cgroup_cpu_periods_total
+//   - cgroup_cpu_throttled_periods_total
+//   - cgroup_cpu_throttled_seconds_total
+//   - cgroup_pids
+//   - cgroup_pids_limit
+//
+// Limits are omitted when unlimited. Throttling, and usage approaching the
+// memory limit, are common causes of latency in containers.
+//
+// This is only supported on Linux, and yields nothing elsewhere, or if the
+// cgroup can't be read.
+func NewCgroupCollector(opts ...CgroupCollectorOption) Collector {
+	c := &cgroupCollector{
+		fsys: os.DirFS("/"),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *cgroupCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
@@ -0,0 +1,192 @@
+//go:build linux
+
+package metrics
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroupfsRoot is where cgroupfs is mounted.
+const cgroupfsRoot = "sys/fs/cgroup"
+
+// cgroup v1 reports unlimited memory as a page aligned math.MaxInt64, so
+// anything this large is treated as unlimited.
+const cgroupV1UnlimitedMemory = 1 << 62
+
+func (c *cgroupCollector) CollectMetrics(w MetricWriter) {
+	paths, err := readCgroupPaths(c.fsys)
+	if err != nil {
+		return
+	}
+	if dir, ok := cgroupV2Dir(c.fsys, paths); ok {
+		c.collectV2(w, dir)
+	} else {
+		c.collectV1(w, paths)
+	}
+}
+
+// cgroupV2Dir returns the directory of the process's cgroup if cgroup v2 is
+// mounted as the unified hierarchy.
+func cgroupV2Dir(fsys fs.FS, paths map[string]string) (string, bool) {
+	if _, err := fs.Stat(fsys, path.Join(cgroupfsRoot, "cgroup.controllers")); err != nil {
+		return "", false
+	}
+	return cgroupDir(fsys, cgroupfsRoot, paths[""]), true
+}
+
+// readCgroupPaths reads the cgroup path of the process for each controller
+// list from /proc/self/cgroup. The unified cgroup v2 hierarchy has an empty
+// controller list.
+//
+// See https://man7.org/linux/man-pages/man7/cgroups.7.html
+func readCgroupPaths(fsys fs.FS) (map[string]string, error) {
+	data, err := fs.ReadFile(fsys, "proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string)
+	for line := range bytes.Lines(data) {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(strings.TrimSpace(string(line)), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		paths[fields[1]] = fields[2]
+	}
+	return paths, nil
+}
+
+// cgroupDir returns the directory of the cgroup at cgroupPath within the
+// hierarchy mounted at mount. Within a cgroup namespace, or a container with
+// only its own cgroup mounted, the path may not be visible, in which case
+// the cgroup is the root of the mount.
+func cgroupDir(fsys fs.FS, mount, cgroupPath string) string {
+	dir := path.Join(mount, cgroupPath)
+	if _, err := fs.Stat(fsys, dir); err != nil {
+		return mount
+	}
+	return dir
+}
+
+func (c *cgroupCollector) collectV2(w MetricWriter, dir string) {
+	if v, err := readUintFile(c.fsys, path.Join(dir, "memory.current")); err == nil {
+		w.WriteGaugeUint64(NewMetricName("cgroup_memory_usage_bytes"), v)
+	}
+	// "max" when unlimited fails to parse, and is skipped
+	if v, err := readUintFile(c.fsys, path.Join(dir, "memory.max")); err == nil {
+		w.WriteGaugeUint64(NewMetricName("cgroup_memory_limit_bytes"), v)
+	}
+	readKeyValues(c.fsys, path.Join(dir, "memory.events"), func(key string, value uint64) {
+		switch key {
+		case "oom":
+			w.WriteCounterUint64(NewMetricName("cgroup_memory_oom_events_total"), value)
+		case "oom_kill":
+			w.WriteCounterUint64(NewMetricName("cgroup_memory_oom_kills_total"), value)
+		}
+	})
+
+	if data, err := fs.ReadFile(c.fsys, path.Join(dir, "cpu.max")); err == nil {
+		// $MAX $PERIOD, where $MAX is "max" when unlimited
+		quota, period, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+		c.writeCPULimit(w, quota, period)
+	}
+	readKeyValues(c.fsys, path.Join(dir, "cpu.stat"), func(key string, value uint64) {
+		switch key {
+		case "usage_usec":
+			w.WriteCounterFloat64(NewMetricName("cgroup_cpu_usage_seconds_total"), (time.Duration(value) * time.Microsecond).Seconds())
+		case "nr_periods":
+			w.WriteCounterUint64(NewMetricName("cgroup_cpu_periods_total"), value)
+		case "nr_throttled":
+			w.WriteCounterUint64(NewMetricName("cgroup_cpu_throttled_periods_total"), value)
+		case "throttled_usec":
+			w.WriteCounterFloat64(NewMetricName("cgroup_cpu_throttled_seconds_total"), (time.Duration(value) * time.Microsecond).Seconds())
+		}
+	})
+
+	c.writePids(w, dir)
+}
+
+func (c *cgroupCollector) collectV1(w MetricWriter, paths map[string]string) {
+	// each hierarchy is mounted at a directory named by its controllers
+	dirs := make(map[string]string, len(paths))
+	for controllers, cgroupPath := range paths {
+		if controllers == "" {
+			continue
+		}
+		dir := cgroupDir(c.fsys, path.Join(cgroupfsRoot, controllers), cgroupPath)
+		for controller := range strings.SplitSeq(controllers, ",") {
+			dirs[controller] = dir
+		}
+	}
+
+	if dir, ok := dirs["memory"]; ok {
+		if v, err := readUintFile(c.fsys, path.Join(dir, "memory.usage_in_bytes")); err == nil {
+			w.WriteGaugeUint64(NewMetricName("cgroup_memory_usage_bytes"), v)
+		}
+		if v, err := readUintFile(c.fsys, path.Join(dir, "memory.limit_in_bytes")); err == nil && v < cgroupV1UnlimitedMemory {
+			w.WriteGaugeUint64(NewMetricName("cgroup_memory_limit_bytes"), v)
+		}
+		readKeyValues(c.fsys, path.Join(dir, "memory.oom_control"), func(key string, value uint64) {
+			if key == "oom_kill" {
+				w.WriteCounterUint64(NewMetricName("cgroup_memory_oom_kills_total"), value)
+			}
+		})
+	}
+
+	if dir, ok := dirs["cpu"]; ok {
+		quota, err1 := fs.ReadFile(c.fsys, path.Join(dir, "cpu.cfs_quota_us"))
+		period, err2 := fs.ReadFile(c.fsys, path.Join(dir, "cpu.cfs_period_us"))
+		if err1 == nil && err2 == nil {
+			c.writeCPULimit(w, string(bytes.TrimSpace(quota)), string(bytes.TrimSpace(period)))
+		}
+	}
+	if dir, ok := dirs["cpuacct"]; ok {
+		if v, err := readUintFile(c.fsys, path.Join(dir, "cpuacct.usage")); err == nil {
+			w.WriteCounterFloat64(NewMetricName("cgroup_cpu_usage_seconds_total"), time.Duration(v).Seconds())
+		}
+	}
+	if dir, ok := dirs["cpu"]; ok {
+		readKeyValues(c.fsys, path.Join(dir, "cpu.stat"), func(key string, value uint64) {
+			switch key {
+			case "nr_periods":
+				w.WriteCounterUint64(NewMetricName("cgroup_cpu_periods_total"), value)
+			case "nr_throttled":
+				w.WriteCounterUint64(NewMetricName("cgroup_cpu_throttled_periods_total"), value)
+			case "throttled_time":
+				w.WriteCounterFloat64(NewMetricName("cgroup_cpu_throttled_seconds_total"), time.Duration(value).Seconds())
+			}
+		})
+	}
+
+	if dir, ok := dirs["pids"]; ok {
+		c.writePids(w, dir)
+	}
+}
+
+// writeCPULimit writes the CPU limit in cores from a CFS quota and period in
+// microseconds. An unlimited quota, "max" or "-1", is skipped.
+func (c *cgroupCollector) writeCPULimit(w MetricWriter, quota, period string) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q < 0 {
+		return
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return
+	}
+	w.WriteGaugeFloat64(NewMetricName("cgroup_cpu_limit_cores"), float64(q)/float64(p))
+}
+
+func (c *cgroupCollector) writePids(w MetricWriter, dir string) {
+	if v, err := readUintFile(c.fsys, path.Join(dir, "pids.current")); err == nil {
+		w.WriteGaugeUint64(NewMetricName("cgroup_pids"), v)
+	}
+	if v, err := readUintFile(c.fsys, path.Join(dir, "pids.max")); err == nil {
+		w.WriteGaugeUint64(NewMetricName("cgroup_pids_limit"), v)
+	}
+}
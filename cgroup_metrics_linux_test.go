@@ -0,0 +1,101 @@
+//go:build linux
+
+package metrics
+
+import (
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCgroupCollectorV2(t *testing.T) {
+	dir := "sys/fs/cgroup/kubepods/pod1/"
+	fsys := fstest.MapFS{
+		"proc/self/cgroup":                 {Data: []byte("0::/kubepods/pod1\n")},
+		"sys/fs/cgroup/cgroup.controllers": {Data: []byte("cpu memory pids\n")},
+		dir + "memory.current":             {Data: []byte("1048576\n")},
+		dir + "memory.max":                 {Data: []byte("4194304\n")},
+		dir + "memory.events":              {Data: []byte("low 0\nhigh 0\nmax 7\noom 2\noom_kill 1\noom_group_kill 0\n")},
+		dir + "cpu.max":                    {Data: []byte("150000 100000\n")},
+		dir + "cpu.stat":                   {Data: []byte("usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\nnr_periods 40\nnr_throttled 4\nthrottled_usec 1500000\n")},
+		dir + "pids.current":               {Data: []byte("12\n")},
+		dir + "pids.max":                   {Data: []byte("max\n")},
+	}
+
+	set := NewSet()
+	set.RegisterCollector(NewCgroupCollector(WithCgroupFS(fsys)))
+	assertMarshal(t, set, []string{
+		"cgroup_memory_usage_bytes 1048576",
+		"cgroup_memory_limit_bytes 4194304",
+		"cgroup_memory_oom_events_total 2",
+		"cgroup_memory_oom_kills_total 1",
+		"cgroup_cpu_limit_cores 1.5",
+		"cgroup_cpu_usage_seconds_total 2.5",
+		"cgroup_cpu_periods_total 40",
+		"cgroup_cpu_throttled_periods_total 4",
+		"cgroup_cpu_throttled_seconds_total 1.5",
+		"cgroup_pids 12",
+	})
+}
+
+func TestCgroupCollectorV2Namespace(t *testing.T) {
+	// within a cgroup namespace, the process's cgroup is the root
+	fsys := fstest.MapFS{
+		"proc/self/cgroup":                 {Data: []byte("0::/\n")},
+		"sys/fs/cgroup/cgroup.controllers": {Data: []byte("memory\n")},
+		"sys/fs/cgroup/memory.current":     {Data: []byte("100\n")},
+		"sys/fs/cgroup/memory.max":         {Data: []byte("max\n")},
+		"sys/fs/cgroup/cpu.max":            {Data: []byte("max 100000\n")},
+	}
+
+	set := NewSet()
+	set.RegisterCollector(NewCgroupCollector(WithCgroupFS(fsys)))
+	assertMarshal(t, set, []string{
+		"cgroup_memory_usage_bytes 100",
+	})
+}
+
+func TestCgroupCollectorV1(t *testing.T) {
+	fsys := fstest.MapFS{
+		"proc/self/cgroup": {Data: []byte(
+			"12:pids:/docker/abc\n" +
+				"4:cpu,cpuacct:/docker/abc\n" +
+				"3:memory:/docker/abc\n" +
+				"1:name=systemd:/docker/abc\n")},
+		// the container only sees its own cgroup at the root of each hierarchy
+		"sys/fs/cgroup/memory/memory.usage_in_bytes":  {Data: []byte("2048\n")},
+		"sys/fs/cgroup/memory/memory.limit_in_bytes":  {Data: []byte("9223372036854771712\n")},
+		"sys/fs/cgroup/memory/memory.oom_control":     {Data: []byte("oom_kill_disable 0\nunder_oom 0\noom_kill 3\n")},
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  {Data: []byte("50000\n")},
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": {Data: []byte("100000\n")},
+		"sys/fs/cgroup/cpu,cpuacct/cpuacct.usage":     {Data: []byte("3000000000\n")},
+		"sys/fs/cgroup/cpu,cpuacct/cpu.stat":          {Data: []byte("nr_periods 10\nnr_throttled 2\nthrottled_time 500000000\n")},
+		"sys/fs/cgroup/pids/pids.current":             {Data: []byte("5\n")},
+		"sys/fs/cgroup/pids/pids.max":                 {Data: []byte("100\n")},
+	}
+
+	set := NewSet()
+	set.RegisterCollector(NewCgroupCollector(WithCgroupFS(fsys)))
+	assertMarshal(t, set, []string{
+		"cgroup_memory_usage_bytes 2048",
+		"cgroup_memory_oom_kills_total 3",
+		"cgroup_cpu_limit_cores 0.5",
+		"cgroup_cpu_usage_seconds_total 3",
+		"cgroup_cpu_periods_total 10",
+		"cgroup_cpu_throttled_periods_total 2",
+		"cgroup_cpu_throttled_seconds_total 0.5",
+		"cgroup_pids 5",
+		"cgroup_pids_limit 100",
+	})
+}
+
+func TestCgroupCollectorMissing(t *testing.T) {
+	set := NewSet()
+	set.RegisterCollector(NewCgroupCollector(WithCgroupFS(fstest.MapFS{})))
+	assertMarshal(t, set, nil)
+
+	// the real cgroup shouldn't fail, whatever it is
+	set = NewSet()
+	set.RegisterCollector(NewCgroupCollector())
+	set.WritePrometheusUnthrottled(io.Discard)
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.withmatt.com/metrics/internal/fasttime"
+)
+
+// CachedCollector returns a Collector which collects c at most once per ttl,
+// and otherwise replays the output of the previous collection. Concurrent
+// scrapes of an expired cache wait for a single collection of c, and share
+// its output and error, or give up once their context is done. If the
+// collection failed because the context of the scrape collecting it was
+// done, the waiting scrapes collect again instead.
+//
+// This is intended for expensive Collectors, such as the Go memstats or
+// process Collectors, when scraped by multiple scrapers. The cache is checked
+// with the same clock as Set TTLs, so ttl has a resolution of about a second.
+//
+// The output of a [ContextCollector] which returned an error isn't cached, so
+// the next scrape collects again.
+func CachedCollector(c Collector, ttl time.Duration) Collector {
+	return &cachedCollector{
+		Collector: c,
+		ttl:       ttl,
+	}
+}
+
+type cachedCollector struct {
+	Collector
+	ttl time.Duration
+
+	// mu guards flight, the collection in progress, so only one scrape
+	// collects at a time
+	mu     sync.Mutex
+	flight *cacheFlight
+	cache  atomic.Pointer[collectorCache]
+}
+
+// cacheFlight is a collection in progress. Its result is set once done is
+// closed.
+type cacheFlight struct {
+	done         chan struct{}
+	constantTags string
+	data         []byte
+	err          error
+	// cancelled is if err is from the context of the collecting scrape,
+	// which isn't shared with the other scrapes
+	cancelled bool
+}
+
+// collectorCache is the output of a collection, which depends on the
+// constant tags it was written with.
+type collectorCache struct {
+	at           fasttime.Instant
+	constantTags string
+	data         []byte
+}
+
+func (c *cachedCollector) load(constantTags string) *collectorCache {
+	cache := c.cache.Load()
+	if cache == nil ||
+		cache.constantTags != constantTags ||
+		fastClock().Since(cache.at) >= c.ttl {
+		return nil
+	}
+	return cache
+}
+
+func (c *cachedCollector) Collect(w ExpfmtWriter) {
+	c.CollectContext(context.Background(), w)
+}
+
+// CollectContext replays the cached output, or collects c if it has expired.
+func (c *cachedCollector) CollectContext(ctx context.Context, w ExpfmtWriter) error {
+	for {
+		if cache := c.load(w.constantTags); cache != nil {
+			w.b.Write(cache.data)
+			return nil
+		}
+
+		c.mu.Lock()
+		// another scrape may have collected meanwhile
+		if cache := c.load(w.constantTags); cache != nil {
+			c.mu.Unlock()
+			w.b.Write(cache.data)
+			return nil
+		}
+		f := c.flight
+		if f == nil {
+			f = &cacheFlight{
+				done:         make(chan struct{}),
+				constantTags: w.constantTags,
+			}
+			c.flight = f
+			c.mu.Unlock()
+			return c.collect(ctx, w, f)
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		// output of other constant tags is collected again, as is output
+		// cut short by the context of the scrape which collected it
+		if f.constantTags == w.constantTags && !f.cancelled {
+			w.b.Write(f.data)
+			return f.err
+		}
+	}
+}
+
+// collect runs the collection f, and caches its output unless it failed.
+func (c *cachedCollector) collect(ctx context.Context, w ExpfmtWriter, f *cacheFlight) error {
+	defer func() {
+		c.mu.Lock()
+		c.flight = nil
+		c.mu.Unlock()
+		close(f.done)
+	}()
+
+	var sizeHint int
+	if prev := c.cache.Load(); prev != nil {
+		sizeHint = len(prev.data)
+	}
+	bb := getScrapeBuffer(sizeHint)
+	defer putScrapeBuffer(bb)
+
+	cw := ExpfmtWriter{b: bb, constantTags: w.constantTags}
+	if cc, ok := c.Collector.(ContextCollector); ok {
+		f.err = cc.CollectContext(ctx, cw)
+	} else {
+		c.Collector.Collect(cw)
+	}
+
+	f.cancelled = f.err != nil && ctx.Err() != nil && errors.Is(f.err, ctx.Err())
+	f.data = bytes.Clone(bb.Bytes())
+	if f.err == nil {
+		c.cache.Store(&collectorCache{
+			at:           fastClock().Now(),
+			constantTags: w.constantTags,
+			data:         f.data,
+		})
+	}
+	w.b.Write(f.data)
+	return f.err
+}
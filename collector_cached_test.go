@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.withmatt.com/metrics/internal/assert"
+	"go.withmatt.com/metrics/internal/fasttime"
+)
+
+func TestCachedCollector(t *testing.T) {
+	var calls atomic.Uint64
+	c := CachedCollector(CollectorFunc(func(w ExpfmtWriter) {
+		time.Sleep(time.Millisecond)
+		w.WriteLazyMetricUint64("calls", calls.Add(1))
+	}), time.Hour)
+
+	set := NewSet("env", "prod")
+	set.RegisterCollector(c)
+
+	// concurrent scrapes share a single collection
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assertMarshal(t, set, []string{`calls{env="prod"} 1`})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, calls.Load(), 1)
+
+	// other constant tags aren't replayed
+	other := NewSet("env", "dev")
+	other.RegisterCollector(c)
+	assertMarshal(t, other, []string{`calls{env="dev"} 2`})
+
+	// expired output is collected again
+	cc := c.(*cachedCollector)
+	cache := *cc.cache.Load()
+	cache.at -= fasttime.Instant(2 * time.Hour)
+	cc.cache.Store(&cache)
+	assertMarshal(t, other, []string{`calls{env="dev"} 3`})
+	assertMarshal(t, other, []string{`calls{env="dev"} 3`})
+}
+
+func TestCachedContextCollector(t *testing.T) {
+	errCollect := errors.New("collect failed")
+	var calls int
+	err := errCollect
+	c := CachedCollector(NamedCollector("test_cached", ContextCollectorFunc(
+		func(ctx context.Context, w ExpfmtWriter) error {
+			calls++
+			w.WriteLazyMetricUint64("foo", uint64(calls))
+			return err
+		},
+	)), time.Hour).(ContextCollector)
+	assert.Equal(t, collectorName(c), "test_cached")
+
+	var b bytes.Buffer
+	w := ExpfmtWriter{b: &b}
+	// failed output isn't cached, so each scrape collects again
+	assert.ErrorIs(t, c.CollectContext(t.Context(), w), errCollect)
+	assert.ErrorIs(t, c.CollectContext(t.Context(), w), errCollect)
+	err = nil
+	assert.Nil(t, c.CollectContext(t.Context(), w))
+	assert.Nil(t, c.CollectContext(t.Context(), w))
+	assert.Equal(t, b.String(), "foo 1\nfoo 2\nfoo 3\nfoo 3\n")
+	assert.Equal(t, calls, 3)
+}
+
+func TestCachedCollectorWaitContext(t *testing.T) {
+	unblock := make(chan struct{})
+	c := CachedCollector(CollectorFunc(func(w ExpfmtWriter) {
+		<-unblock
+		w.WriteLazyMetricUint64("slow", 1)
+	}), time.Hour).(ContextCollector)
+	cc := c.(*cachedCollector)
+
+	collected := make(chan string)
+	go func() {
+		var b bytes.Buffer
+		c.CollectContext(t.Context(), ExpfmtWriter{b: &b})
+		collected <- b.String()
+	}()
+	for {
+		cc.mu.Lock()
+		inFlight := cc.flight != nil
+		cc.mu.Unlock()
+		if inFlight {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a waiting scrape gives up once its context is done
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	var b bytes.Buffer
+	assert.ErrorIs(t, c.CollectContext(ctx, ExpfmtWriter{b: &b}), context.DeadlineExceeded)
+	assert.Equal(t, b.String(), "")
+
+	close(unblock)
+	assert.Equal(t, <-collected, "slow 1\n")
+	assert.Nil(t, c.CollectContext(t.Context(), ExpfmtWriter{b: &b}))
+	assert.Equal(t, b.String(), "slow 1\n")
+}
+
+func TestCachedCollectorLeaderCancelled(t *testing.T) {
+	var calls atomic.Int64
+	c := CachedCollector(ContextCollectorFunc(func(ctx context.Context, w ExpfmtWriter) error {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			w.WriteLazyMetricUint64("partial", 1)
+			return ctx.Err()
+		}
+		w.WriteLazyMetricUint64("full", 1)
+		return nil
+	}), time.Hour).(ContextCollector)
+	cc := c.(*cachedCollector)
+
+	leaderCtx, cancel := context.WithCancel(t.Context())
+	leader := make(chan error)
+	go func() {
+		var b bytes.Buffer
+		leader <- c.CollectContext(leaderCtx, ExpfmtWriter{b: &b})
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waiter := make(chan string)
+	go func() {
+		var b bytes.Buffer
+		assert.Nil(t, c.CollectContext(t.Context(), ExpfmtWriter{b: &b}))
+		waiter <- b.String()
+	}()
+	// let the waiter wait for the leader's collection
+	time.Sleep(10 * time.Millisecond)
+	cc.mu.Lock()
+	assert.NotNil(t, cc.flight)
+	cc.mu.Unlock()
+
+	// the leader's cancellation isn't the waiter's failure, so it collects
+	// again rather than getting the partial output
+	cancel()
+	assert.ErrorIs(t, <-leader, context.Canceled)
+	assert.Equal(t, <-waiter, "full 1\n")
+	assert.Equal(t, calls.Load(), 2)
+}
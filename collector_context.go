@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ContextCollector is a Collector which is given the context of the scrape,
+// and reports when it fails to collect rather than silently writing partial
+// output. When registered onto a Set, CollectContext is called instead of
+// Collect.
+//
+// Whatever was written before an error is still part of the scrape. Errors
+// are counted in gometrics_collector_errors_total, see [WithCollectorStats],
+// and of all Sets in gometrics_collect_errors_total, see
+// [NewSelfMetricsCollector]. They are also passed to the handler set by
+// [WithCollectorErrorHandler].
+type ContextCollector interface {
+	Collector
+	CollectContext(ctx context.Context, w ExpfmtWriter) error
+}
+
+// ContextCollectorFunc is an adapter to allow the use of ordinary functions as
+// ContextCollectors.
+type ContextCollectorFunc func(ctx context.Context, w ExpfmtWriter) error
+
+// Collect calls f with a background context, and ignores its error.
+func (f ContextCollectorFunc) Collect(w ExpfmtWriter) {
+	f(context.Background(), w)
+}
+
+// CollectContext calls f(ctx, w).
+func (f ContextCollectorFunc) CollectContext(ctx context.Context, w ExpfmtWriter) error {
+	return f(ctx, w)
+}
+
+// WithCollectorErrorHandler sets a function called with the name of a
+// Collector and the error it returned, see [ContextCollector] and
+// [NamedCollector]. With [WithParallelCollectors], f may be called
+// concurrently.
+func WithCollectorErrorHandler(f func(name string, err error)) CollectOption {
+	return func(c *collectConfig) { c.onError = f }
+}
+
+// CollectContext calls CollectContext of the named Collector if it is a
+// ContextCollector.
+func (nc *namedCollector) CollectContext(ctx context.Context, w ExpfmtWriter) error {
+	if cc, ok := nc.Collector.(ContextCollector); ok {
+		return cc.CollectContext(ctx, w)
+	}
+	nc.Collector.Collect(w)
+	return nil
+}
+
+// collectErrors counts the errors of the ContextCollectors of all Sets.
+var collectErrors atomic.Uint64
+
+// collect runs the Collector, preferring CollectContext, and reports and
+// returns its error.
+func (rc *registeredCollector) collect(ctx context.Context, cfg *collectConfig, w ExpfmtWriter) error {
+	cc, ok := rc.Collector.(ContextCollector)
+	if !ok {
+		rc.Collector.Collect(w)
+		return nil
+	}
+	return rc.reportError(cfg, cc.CollectContext(ctx, w))
+}
+
+// reportError counts and reports the error of a run of the Collector, if
+// any, and returns it.
+func (rc *registeredCollector) reportError(cfg *collectConfig, err error) error {
+	if err != nil {
+		rc.errors.Add(1)
+		// the errors of a nested Set were already counted by its own
+		// Collectors
+		if _, nested := rc.Collector.(*Set); !nested {
+			collectErrors.Add(1)
+		}
+		if cfg != nil && cfg.onError != nil {
+			cfg.onError(rc.name, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+type testContextKey struct{}
+
+func TestContextCollector(t *testing.T) {
+	errCollect := errors.New("collect failed")
+
+	for _, parallel := range []bool{false, true} {
+		var (
+			mu     sync.Mutex
+			names  []string
+			errs   []error
+			values []any
+		)
+		set := NewSet()
+		set.RegisterCollector(
+			NamedCollector("test_context", ContextCollectorFunc(func(ctx context.Context, w ExpfmtWriter) error {
+				mu.Lock()
+				values = append(values, ctx.Value(testContextKey{}))
+				mu.Unlock()
+				w.WriteLazyMetricUint64("partial", 1)
+				return errCollect
+			})),
+			CollectorFunc(func(w ExpfmtWriter) {
+				w.WriteLazyMetricUint64("plain", 2)
+			}),
+		)
+		opts := []CollectOption{WithCollectorErrorHandler(func(name string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			names = append(names, name)
+			errs = append(errs, err)
+		})}
+		if parallel {
+			opts = append(opts, WithParallelCollectors())
+		}
+		set.SetCollectOptions(opts...)
+
+		ctx := context.WithValue(t.Context(), testContextKey{}, "scrape")
+		var b bytes.Buffer
+		_, err := set.WritePrometheusContext(ctx, &b)
+		assert.Nil(t, err)
+		// output written before the error is kept
+		assert.Equal(t, b.String(), "partial 1\nplain 2\n")
+
+		assert.SlicesEqual(t, names, []string{"test_context"})
+		assert.Equal(t, len(errs), 1)
+		assert.ErrorIs(t, errs[0], errCollect)
+		assert.SlicesEqual(t, values, []any{"scrape"})
+		assert.Equal(t, registration(set, 0).errors.Load(), 1)
+	}
+}
+
+func TestContextCollectorFunc(t *testing.T) {
+	var gotCtx context.Context
+	f := ContextCollectorFunc(func(ctx context.Context, w ExpfmtWriter) error {
+		gotCtx = ctx
+		w.WriteLazyMetricUint64("foo", 1)
+		return errors.New("ignored")
+	})
+
+	var b bytes.Buffer
+	f.Collect(ExpfmtWriter{b: &b})
+	assert.Equal(t, b.String(), "foo 1\n")
+	assert.Equal(t, gotCtx, context.Background())
+
+	// errors are counted without any CollectOptions
+	set := NewSet()
+	set.RegisterCollector(f)
+	set.WritePrometheus(&b)
+	assert.Equal(t, registration(set, 0).errors.Load(), 1)
+	assert.Equal(t, registration(set, 0).name, "metrics.ContextCollectorFunc")
+}
+
+func TestSetContextCollector(t *testing.T) {
+	errCollect := errors.New("collect failed")
+	var values []any
+
+	child := NewSet("env", "prod")
+	child.RegisterCollector(ContextCollectorFunc(func(ctx context.Context, w ExpfmtWriter) error {
+		values = append(values, ctx.Value(testContextKey{}))
+		w.WriteLazyMetricUint64("partial", 1)
+		return errCollect
+	}))
+	parent := NewSet("region", "x")
+	parent.RegisterCollector(child)
+	var _ ContextCollector = child
+
+	before := collectErrors.Load()
+	ctx := context.WithValue(t.Context(), testContextKey{}, "scrape")
+	var b bytes.Buffer
+	_, err := parent.WritePrometheusContext(ctx, &b)
+	assert.Nil(t, err)
+	assert.Equal(t, b.String(), `partial{region="x",env="prod"} 1`+"\n")
+
+	// the context reaches the Collectors of the nested Set, and their
+	// errors are returned to the Set it's registered on
+	assert.SlicesEqual(t, values, []any{"scrape"})
+	assert.Equal(t, registration(child, 0).errors.Load(), 1)
+	assert.Equal(t, registration(parent, 0).errors.Load(), 1)
+	assert.ErrorIs(t, child.CollectContext(ctx, ExpfmtWriter{b: &b}), errCollect)
+
+	// each error is counted once in the self metrics
+	assert.Equal(t, collectErrors.Load(), before+2)
+	b.Reset()
+	NewSelfMetricsCollector().Collect(ExpfmtWriter{b: &b})
+	assert.True(t, strings.Contains(b.String(),
+		"gometrics_collect_errors_total "+strconv.FormatUint(before+2, 10)+"\n"))
+}
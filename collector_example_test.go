@@ -2,7 +2,11 @@ package metrics_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"expvar"
 	"fmt"
+	"time"
 
 	"go.withmatt.com/metrics"
 )
@@ -111,3 +115,109 @@ func ExampleSet_Collect_withAppendConstantTags() {
 	// errors{app="myservice",module="api"} 10
 	// requests{app="myservice",module="api"} 500
 }
+
+func ExampleNewExpvarCollector() {
+	hits := expvar.NewMap("example_cache_hits")
+	hits.Add("users", 3)
+	hits.Add("items", 1)
+
+	set := metrics.NewSet()
+	set.RegisterCollector(metrics.NewExpvarCollector("example_cache_hits"))
+
+	var buf bytes.Buffer
+	set.WritePrometheus(&buf)
+	fmt.Print(buf.String())
+
+	// Output:
+	// example_cache_hits{key="items"} 1
+	// example_cache_hits{key="users"} 3
+}
+
+func ExampleMetricCollectorFunc() {
+	// A MetricCollector writes typed values to any MetricWriter, so it
+	// isn't tied to the Prometheus text format.
+	collector := metrics.MetricCollectorFunc(func(w metrics.MetricWriter) {
+		w.WriteCounterUint64(metrics.NewMetricName("jobs_total", "queue", "default"), 10)
+		w.WriteGaugeFloat64(metrics.NewMetricName("queue_utilization_ratio"), 0.5)
+	})
+
+	set := metrics.NewSet()
+	set.RegisterCollector(collector)
+
+	var b bytes.Buffer
+	set.WritePrometheus(&b)
+	fmt.Print(b.String())
+
+	// Output:
+	// jobs_total{queue="default"} 10
+	// queue_utilization_ratio 0.5
+}
+
+func ExampleWithParallelCollectors() {
+	set := metrics.NewSet()
+	set.RegisterCollector(
+		metrics.NamedCollector("slow", metrics.CollectorFunc(func(w metrics.ExpfmtWriter) {
+			time.Sleep(10 * time.Millisecond)
+			w.WriteLazyMetricUint64("slow", 1)
+		})),
+		metrics.NamedCollector("fast", metrics.CollectorFunc(func(w metrics.ExpfmtWriter) {
+			w.WriteLazyMetricUint64("fast", 2)
+		})),
+	)
+	// Collectors run concurrently, and any that don't finish within a second
+	// are left out of the scrape.
+	set.SetCollectOptions(
+		metrics.WithParallelCollectors(),
+		metrics.WithCollectorTimeout(time.Second),
+	)
+
+	var b bytes.Buffer
+	set.WritePrometheusContext(context.Background(), &b)
+	fmt.Print(b.String())
+
+	// Output:
+	// slow 1
+	// fast 2
+}
+
+func ExampleContextCollectorFunc() {
+	set := metrics.NewSet()
+	set.RegisterCollector(metrics.NamedCollector("queue", metrics.ContextCollectorFunc(
+		func(ctx context.Context, w metrics.ExpfmtWriter) error {
+			w.WriteLazyMetricUint64("queue_depth", 3)
+			return errors.New("queue latency unavailable")
+		},
+	)))
+	set.SetCollectOptions(metrics.WithCollectorErrorHandler(func(name string, err error) {
+		fmt.Printf("collector %s: %v\n", name, err)
+	}))
+
+	var b bytes.Buffer
+	set.WritePrometheusContext(context.Background(), &b)
+	fmt.Print(b.String())
+
+	// Output:
+	// collector queue: queue latency unavailable
+	// queue_depth 3
+}
+
+func ExampleCachedCollector() {
+	var calls uint64
+	set := metrics.NewSet()
+	// Collected at most once a minute, no matter how many scrapers there are.
+	set.RegisterCollector(metrics.CachedCollector(metrics.CollectorFunc(func(w metrics.ExpfmtWriter) {
+		calls++
+		w.WriteLazyMetricUint64("expensive_calls", calls)
+	}), time.Minute))
+
+	for range 3 {
+		var b bytes.Buffer
+		set.WritePrometheus(&b)
+		fmt.Print(b.String())
+	}
+
+	// Output:
+	// expensive_calls 1
+	// expensive_calls 1
+	// expensive_calls 1
+}
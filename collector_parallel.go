@@ -0,0 +1,344 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.withmatt.com/metrics/internal/atomicx"
+)
+
+// CollectOption configures how a [Set] runs its Collectors.
+type CollectOption func(*collectConfig)
+
+type collectConfig struct {
+	parallel bool
+	timeout  time.Duration
+	stats    bool
+	onError  func(name string, err error)
+}
+
+// WithParallelCollectors runs the Collectors of a Set concurrently, each into
+// its own buffer, and writes their output in registration order. This keeps a
+// slow Collector from delaying all others.
+//
+// A Collector isn't run again while it's still running: concurrent scrapes
+// with the same constant tags wait for, and write, the output of the same
+// run. As a run is shared, a [ContextCollector] is given a context which
+// isn't cancelled along with any one scrape, but is bounded by
+// [WithCollectorTimeout]. Each scrape still stops waiting once its own
+// context is done.
+func WithParallelCollectors() CollectOption {
+	return func(c *collectConfig) { c.parallel = true }
+}
+
+// WithCollectorTimeout sets how long parallel Collectors are waited for,
+// in addition to the deadline of the scrape's context. The output of a
+// Collector that doesn't finish in time is dropped from the scrape, and
+// counted in gometrics_collector_timeouts_total, see [WithCollectorStats].
+//
+// Collectors can't be interrupted, so a Collector that timed out keeps
+// running in the background until it returns. Until then, it isn't started
+// again, and later scrapes wait for the same run, dropping it as well if it
+// doesn't finish in time.
+//
+// This only applies along with [WithParallelCollectors].
+func WithCollectorTimeout(d time.Duration) CollectOption {
+	return func(c *collectConfig) { c.timeout = d }
+}
+
+// WithCollectorStats writes metrics about each Collector of a Set after
+// their output, with the constant tags of the Set:
+//
+//   - gometrics_collector_duration_seconds{collector="..."}
+//   - gometrics_collector_timeouts_total{collector="..."}
+//   - gometrics_collector_errors_total{collector="..."}
+//
+// The `collector` tag is the name from [NamedCollector], or otherwise the
+// type of the Collector. Repeated names within the Set are suffixed with
+// their occurrence, such as "metrics.CollectorFunc#2".
+func WithCollectorStats() CollectOption {
+	return func(c *collectConfig) { c.stats = true }
+}
+
+// SetCollectOptions configures how the Collectors of the global Set are run.
+// See [Set.SetCollectOptions].
+func SetCollectOptions(opts ...CollectOption) {
+	defaultSet.SetCollectOptions(opts...)
+}
+
+// SetCollectOptions configures how the Collectors registered directly on s
+// are run. Children Sets are configured separately.
+func (s *Set) SetCollectOptions(opts ...CollectOption) {
+	var c collectConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	s.collectConfig.Store(&c)
+}
+
+// NamedCollector returns c with a name, which is used as the `collector` tag
+// of [WithCollectorStats]. Without a name, a Collector is named by its type.
+func NamedCollector(name string, c Collector) Collector {
+	return &namedCollector{name: name, Collector: c}
+}
+
+type namedCollector struct {
+	Collector
+	name string
+}
+
+func collectorName(c Collector) string {
+	switch c := c.(type) {
+	case *namedCollector:
+		return c.name
+	case *cachedCollector:
+		return collectorName(c.Collector)
+	}
+	return reflect.TypeOf(c).String()
+}
+
+// registeredCollector is a Collector registered onto a Set, along with its
+// stats.
+type registeredCollector struct {
+	Collector
+	name string
+
+	duration atomicx.Float64
+	timeouts atomic.Uint64
+	errors   atomic.Uint64
+
+	// mu guards the in-flight parallel runs of the Collector by the constant
+	// tags they are written with, and the size of the output of the
+	// previous run
+	mu       sync.Mutex
+	running  map[string]*collectorRun
+	lastSize int
+}
+
+func newRegisteredCollector(c Collector) *registeredCollector {
+	return &registeredCollector{
+		Collector: c,
+		name:      collectorName(c),
+	}
+}
+
+var (
+	collectorDurationFamily = MustIdent("gometrics_collector_duration_seconds")
+	collectorTimeoutsFamily = MustIdent("gometrics_collector_timeouts_total")
+	collectorErrorsFamily   = MustIdent("gometrics_collector_errors_total")
+	collectorLabel          = MustLabel("collector")
+)
+
+func writeCollectorStats(w MetricWriter, collectors []*registeredCollector) {
+	names := make([]MetricName, len(collectors))
+	seen := make(map[string]int, len(collectors))
+	for i, rc := range collectors {
+		name := rc.name
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name += "#" + strconv.Itoa(n)
+		}
+		names[i].Tags = []Tag{NewTag(collectorLabel, SanitizeValue(name))}
+	}
+
+	for i, rc := range collectors {
+		names[i].Family = collectorDurationFamily
+		w.WriteGaugeFloat64(names[i], rc.duration.Load())
+	}
+	for i, rc := range collectors {
+		names[i].Family = collectorTimeoutsFamily
+		w.WriteCounterUint64(names[i], rc.timeouts.Load())
+	}
+	for i, rc := range collectors {
+		names[i].Family = collectorErrorsFamily
+		w.WriteCounterUint64(names[i], rc.errors.Load())
+	}
+}
+
+// runCollectors writes the output of the Collectors of s to w, in
+// registration order, and returns the errors of the Collectors joined. each
+// is called after the output of each Collector, with where the output
+// started in w's buffer, and collecting stops if it returns false.
+func (s *Set) runCollectors(
+	ctx context.Context,
+	w ExpfmtWriter,
+	throttle bool,
+	each func(start int) bool,
+) (keepGoing bool, err error) {
+	collectors := s.collectors.Load()
+	if collectors == nil {
+		return true, nil
+	}
+
+	var errs []error
+	cfg := s.collectConfig.Load()
+	if cfg != nil && cfg.parallel {
+		if keepGoing, errs = runParallelCollectors(ctx, w, *collectors, cfg, each); !keepGoing {
+			return false, errors.Join(errs...)
+		}
+	} else {
+		for _, rc := range *collectors {
+			// yield the scheduler for each Collector to not starve CPU
+			if throttle {
+				runtime.Gosched()
+			}
+			start := w.b.Len()
+			began := time.Now()
+			if err := rc.collect(ctx, cfg, w); err != nil {
+				errs = append(errs, err)
+			}
+			rc.duration.Store(time.Since(began).Seconds())
+			if each != nil && !each(start) {
+				return false, errors.Join(errs...)
+			}
+		}
+	}
+
+	keepGoing = true
+	if cfg != nil && cfg.stats {
+		start := w.b.Len()
+		writeCollectorStats(w, *collectors)
+		if each != nil {
+			keepGoing = each(start)
+		}
+	}
+	return keepGoing, errors.Join(errs...)
+}
+
+// collectorRun is a Collector running in its own goroutine, shared by the
+// scrapes waiting for it.
+type collectorRun struct {
+	bb *bytes.Buffer
+	// err is the error of the Collector, set once done is closed
+	err  error
+	done chan struct{}
+	// refs is the number of holders of bb, guarded by the mu of the
+	// registeredCollector
+	refs int
+}
+
+// start returns the in-flight run of rc with the constant tags, or otherwise
+// starts one. A run isn't bound to the ctx of the scrape starting it, since
+// other scrapes may wait for it too, only to the values of ctx and the
+// timeout of cfg. The caller holds a reference to the run until it calls
+// rc.release.
+func (rc *registeredCollector) start(
+	ctx context.Context,
+	cfg *collectConfig,
+	constantTags string,
+) *collectorRun {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if run, ok := rc.running[constantTags]; ok {
+		run.refs++
+		return run
+	}
+
+	run := &collectorRun{
+		bb:   getScrapeBuffer(rc.lastSize),
+		done: make(chan struct{}),
+		// held by both the caller and the goroutine
+		refs: 2,
+	}
+	if rc.running == nil {
+		rc.running = make(map[string]*collectorRun)
+	}
+	rc.running[constantTags] = run
+
+	ctx = context.WithoutCancel(ctx)
+	cancel := context.CancelFunc(func() {})
+	if cfg.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+	}
+	go func() {
+		defer cancel()
+		began := time.Now()
+		run.err = rc.collect(ctx, cfg, ExpfmtWriter{
+			b:            run.bb,
+			constantTags: constantTags,
+		})
+		rc.duration.Store(time.Since(began).Seconds())
+
+		rc.mu.Lock()
+		delete(rc.running, constantTags)
+		rc.lastSize = run.bb.Len()
+		run.refs--
+		unused := run.refs == 0
+		rc.mu.Unlock()
+
+		close(run.done)
+		if unused {
+			putScrapeBuffer(run.bb)
+		}
+	}()
+	return run
+}
+
+// release drops a reference to run, and releases its buffer once it's unused.
+func (rc *registeredCollector) release(run *collectorRun) {
+	rc.mu.Lock()
+	run.refs--
+	unused := run.refs == 0
+	rc.mu.Unlock()
+	if unused {
+		putScrapeBuffer(run.bb)
+	}
+}
+
+func runParallelCollectors(
+	ctx context.Context,
+	w ExpfmtWriter,
+	collectors []*registeredCollector,
+	cfg *collectConfig,
+	each func(start int) bool,
+) (keepGoing bool, errs []error) {
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+
+	runs := make([]*collectorRun, len(collectors))
+	for i, rc := range collectors {
+		runs[i] = rc.start(ctx, cfg, w.constantTags)
+	}
+
+	keepGoing = true
+	for i, rc := range collectors {
+		run := runs[i]
+		if !keepGoing {
+			rc.release(run)
+			continue
+		}
+
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			select {
+			case <-run.done:
+			default:
+				rc.timeouts.Add(1)
+				rc.release(run)
+				continue
+			}
+		}
+
+		start := w.b.Len()
+		w.b.Write(run.bb.Bytes())
+		if run.err != nil {
+			errs = append(errs, run.err)
+		}
+		rc.release(run)
+		if each != nil && !each(start) {
+			keepGoing = false
+		}
+	}
+	return keepGoing, errs
+}
@@ -0,0 +1,308 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+// registration returns the i-th Collector registered onto set.
+func registration(set *Set, i int) *registeredCollector {
+	return (*set.collectors.Load())[i]
+}
+
+// runRefs returns the references to the in-flight run of rc with the
+// constant tags, if any.
+func runRefs(rc *registeredCollector, constantTags string) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if run, ok := rc.running[constantTags]; ok {
+		return run.refs
+	}
+	return 0
+}
+
+func TestParallelCollectors(t *testing.T) {
+	set := NewSet("env", "prod")
+	set.NewCounter("foo").Inc()
+	set.RegisterCollector(
+		NamedCollector("test_slow", CollectorFunc(func(w ExpfmtWriter) {
+			time.Sleep(10 * time.Millisecond)
+			w.WriteLazyMetricUint64("slow", 1)
+		})),
+		NamedCollector("test_fast", CollectorFunc(func(w ExpfmtWriter) {
+			w.WriteLazyMetricUint64("fast", 2)
+		})),
+	)
+
+	want := []string{
+		`foo{env="prod"} 1`,
+		`slow{env="prod"} 1`,
+		`fast{env="prod"} 2`,
+	}
+	assertMarshal(t, set, want)
+
+	set.SetCollectOptions(WithParallelCollectors())
+	// output is in registration order, regardless of which finished first
+	assertMarshal(t, set, want)
+
+	var b bytes.Buffer
+	set.WritePrometheusStreaming(&b)
+	assert.Equal(t, b.String(), strings.Join(want, "\n")+"\n")
+
+	b.Reset()
+	set.WritePrometheusSorted(&b)
+	assert.Equal(t, b.String(), `fast{env="prod"} 2
+foo{env="prod"} 1
+slow{env="prod"} 1
+`)
+
+	slow := registration(set, 0)
+	assert.Greater(t, slow.duration.Load(), 0.01)
+	assert.Equal(t, slow.timeouts.Load(), 0)
+}
+
+func TestParallelCollectorsTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	set := NewSet()
+	set.RegisterCollector(
+		NamedCollector("test_hang", CollectorFunc(func(w ExpfmtWriter) {
+			<-unblock
+			w.WriteLazyMetricUint64("hang", 1)
+		})),
+		NamedCollector("test_ok", CollectorFunc(func(w ExpfmtWriter) {
+			w.WriteLazyMetricUint64("ok", 1)
+		})),
+	)
+	set.SetCollectOptions(
+		WithParallelCollectors(),
+		WithCollectorTimeout(10*time.Millisecond),
+	)
+
+	// the hanging Collector is dropped, and the others still written
+	assertMarshal(t, set, []string{"ok 1"})
+	assert.Equal(t, registration(set, 0).timeouts.Load(), 1)
+	assert.Equal(t, registration(set, 1).timeouts.Load(), 0)
+}
+
+func TestParallelCollectorsHung(t *testing.T) {
+	unblock := make(chan struct{})
+	var started atomic.Int64
+
+	set := NewSet()
+	set.RegisterCollector(NamedCollector("test_hung", CollectorFunc(func(w ExpfmtWriter) {
+		started.Add(1)
+		<-unblock
+		w.WriteLazyMetricUint64("hung", 1)
+	})))
+	set.SetCollectOptions(
+		WithParallelCollectors(),
+		WithCollectorTimeout(10*time.Millisecond),
+	)
+
+	// a Collector still running isn't started again by later scrapes
+	for range 3 {
+		assertMarshal(t, set, nil)
+	}
+	assert.Equal(t, started.Load(), 1)
+	assert.Equal(t, registration(set, 0).timeouts.Load(), 3)
+
+	// once it returns, the next scrape runs it again
+	close(unblock)
+	for runRefs(registration(set, 0), "") > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assertMarshal(t, set, []string{"hung 1"})
+	assert.Equal(t, started.Load(), 2)
+}
+
+func TestParallelCollectorsConcurrentScrapes(t *testing.T) {
+	unblock := make(chan struct{})
+	var started atomic.Int64
+
+	set := NewSet()
+	set.RegisterCollector(CollectorFunc(func(w ExpfmtWriter) {
+		started.Add(1)
+		<-unblock
+		w.WriteLazyMetricUint64("shared", 1)
+	}))
+	set.SetCollectOptions(WithParallelCollectors())
+
+	// scrapes of a running Collector share its output
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assertMarshal(t, set, []string{"shared 1"})
+		}()
+	}
+	for runRefs(registration(set, 0), "") < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	close(unblock)
+	wg.Wait()
+	assert.Equal(t, started.Load(), 1)
+}
+
+func TestParallelCollectorsConstantTags(t *testing.T) {
+	unblock := make(chan struct{})
+	var started atomic.Int64
+
+	set := NewSet("env", "prod")
+	set.RegisterCollector(CollectorFunc(func(w ExpfmtWriter) {
+		started.Add(1)
+		<-unblock
+		w.WriteLazyMetricUint64("tagged", 1)
+	}))
+	set.SetCollectOptions(WithParallelCollectors())
+	parent := NewSet("region", "x")
+	parent.RegisterCollector(set)
+
+	// the output of a run is only shared by scrapes with the same tags
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assertMarshal(t, set, []string{`tagged{env="prod"} 1`})
+	}()
+	go func() {
+		defer wg.Done()
+		assertMarshal(t, parent, []string{`tagged{region="x",env="prod"} 1`})
+	}()
+	for started.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(unblock)
+	wg.Wait()
+	assert.Equal(t, started.Load(), 2)
+}
+
+func TestParallelCollectorsSharedContext(t *testing.T) {
+	unblock := make(chan struct{})
+	set := NewSet()
+	set.RegisterCollector(ContextCollectorFunc(func(ctx context.Context, w ExpfmtWriter) error {
+		select {
+		case <-unblock:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		w.WriteLazyMetricUint64("shared", 1)
+		return nil
+	}))
+	set.SetCollectOptions(WithParallelCollectors())
+
+	// the scrape starting the run gives up, without cancelling the run
+	// another scrape still waits for
+	short, cancel := context.WithCancel(context.Background())
+	done := make(chan string)
+	go func() {
+		var b bytes.Buffer
+		set.WritePrometheusContext(short, &b)
+		done <- b.String()
+	}()
+	for runRefs(registration(set, 0), "") < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		var b bytes.Buffer
+		set.WritePrometheusContext(context.Background(), &b)
+		done <- b.String()
+	}()
+	for runRefs(registration(set, 0), "") < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	assert.Equal(t, <-done, "")
+
+	close(unblock)
+	assert.Equal(t, <-done, "shared 1\n")
+	assert.Equal(t, registration(set, 0).errors.Load(), 0)
+	assert.Equal(t, registration(set, 0).timeouts.Load(), 1)
+}
+
+func TestParallelCollectorsContext(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	set := NewSet()
+	set.NewCounter("foo").Inc()
+	set.RegisterCollector(NamedCollector("test_ctx", CollectorFunc(func(w ExpfmtWriter) {
+		<-unblock
+		w.WriteLazyMetricUint64("hang", 1)
+	})))
+	set.SetCollectOptions(WithParallelCollectors())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	for i, write := range []func(context.Context, io.Writer) (int, error){
+		set.WritePrometheusContext,
+		set.WritePrometheusStreamingContext,
+		set.WritePrometheusSortedContext,
+	} {
+		var b bytes.Buffer
+		_, err := write(ctx, &b)
+		assert.Nil(t, err)
+		assert.Equal(t, b.String(), "foo 1\n")
+		assert.Equal(t, registration(set, 0).timeouts.Load(), uint64(i+1))
+	}
+}
+
+func TestCollectorStats(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		set := NewSet("env", "prod")
+		set.RegisterCollector(
+			NamedCollector(`test "stats"`, CollectorFunc(func(w ExpfmtWriter) {})),
+			CollectorFunc(func(w ExpfmtWriter) {}),
+			CollectorFunc(func(w ExpfmtWriter) {}),
+		)
+		opts := []CollectOption{WithCollectorStats()}
+		if parallel {
+			opts = append(opts, WithParallelCollectors())
+		}
+		set.SetCollectOptions(opts...)
+
+		var b bytes.Buffer
+		set.WritePrometheus(&b)
+		lines := splitLines(strings.TrimSpace(b.String()))
+		assert.Equal(t, len(lines), 9)
+		assert.True(t, strings.HasPrefix(lines[0], `gometrics_collector_duration_seconds{env="prod",collector="test \"stats\""} `))
+
+		// stats are of each registration, with the constant tags of the Set
+		for i := range 3 {
+			registration(set, i).duration.Store(0.5)
+		}
+		w := ExpfmtWriter{b: &b, constantTags: set.constantTags}
+		b.Reset()
+		writeCollectorStats(w, *set.collectors.Load())
+		assert.LinesEqual(t, splitLines(strings.TrimSpace(b.String())), splitLines(strings.Join([]string{
+			`gometrics_collector_duration_seconds{env="prod",collector="test \"stats\""} 0.5`,
+			`gometrics_collector_duration_seconds{env="prod",collector="metrics.CollectorFunc"} 0.5`,
+			`gometrics_collector_duration_seconds{env="prod",collector="metrics.CollectorFunc#2"} 0.5`,
+			`gometrics_collector_timeouts_total{env="prod",collector="test \"stats\""} 0`,
+			`gometrics_collector_timeouts_total{env="prod",collector="metrics.CollectorFunc"} 0`,
+			`gometrics_collector_timeouts_total{env="prod",collector="metrics.CollectorFunc#2"} 0`,
+			`gometrics_collector_errors_total{env="prod",collector="test \"stats\""} 0`,
+			`gometrics_collector_errors_total{env="prod",collector="metrics.CollectorFunc"} 0`,
+			`gometrics_collector_errors_total{env="prod",collector="metrics.CollectorFunc#2"} 0`,
+		}, "\n")))
+	}
+
+	// another Set of the same Collector has its own stats
+	c := NamedCollector("shared", CollectorFunc(func(w ExpfmtWriter) {}))
+	a, b := NewSet(), NewSet()
+	a.RegisterCollector(c)
+	b.RegisterCollector(c)
+	registration(a, 0).timeouts.Add(1)
+	assert.Equal(t, registration(b, 0).timeouts.Load(), 0)
+}
@@ -67,17 +67,26 @@ func NewUint64(family string, tags ...string) *Uint64 {
 //
 // This will panic if values are invalid or already registered.
 func (s *Set) NewUint64(family string, tags ...string) *Uint64 {
-	c := &Uint64{}
-	s.mustStoreMetric(c, MetricName{
-		Family: MustIdent(family),
-		Tags:   MustTags(tags...),
-	})
-	return c
+	return s.newUint64(family, tags, false)
 }
 
-// NewCounter is an alias for [Set.NewUint64].
+// NewCounter is an alias for [Set.NewUint64], which marks the Uint64 as a
+// counter for [Set.Gather].
 func (s *Set) NewCounter(family string, tags ...string) *Uint64 {
-	return s.NewUint64(family, tags...)
+	return s.newUint64(family, tags, true)
+}
+
+func (s *Set) newUint64(family string, tags []string, counter bool) *Uint64 {
+	c := &Uint64{}
+	s.mustStoreNamedMetric(&namedMetric{
+		name: MetricName{
+			Family: MustIdent(family),
+			Tags:   MustTags(tags...),
+		},
+		metric:  c,
+		counter: counter,
+	})
+	return c
 }
 
 // Int64 is an int64 counter.
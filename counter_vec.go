@@ -4,6 +4,8 @@ package metrics
 // by the same metric name and tag labels, but different tag values.
 type Uint64Vec struct {
 	commonVec
+	// counter marks each Uint64 as a counter, see [Set.NewCounterVec]
+	counter bool
 }
 
 // NewUint64Vec creates a new Uint64Vec on the global Set.
@@ -35,10 +37,11 @@ func (c *Uint64Vec) WithLabelValues(values ...string) *Uint64 {
 func (c *Uint64Vec) withLabelValues(set *Set, values []string) *Uint64 {
 	hash := hashFinish(c.partialHash, values...)
 
-	nm, ok := set.metrics.Load(hash)
+	nm, ok := set.loadMetricFromVec(hash, c.family, c.partialTags, values)
 	if !ok {
-		nm = set.loadOrStoreMetricFromVec(
-			&Uint64{}, hash, c.family, c.partialTags, values,
+		nm = set.loadOrStoreNamedMetricFromVec(
+			&namedMetric{metric: &Uint64{}, counter: c.counter},
+			hash, c.family, c.partialTags, values,
 		)
 	}
 	return nm.metric.(*Uint64)
@@ -46,12 +49,13 @@ func (c *Uint64Vec) withLabelValues(set *Set, values []string) *Uint64 {
 
 // NewUint64Vec creates a new [Uint64Vec] with the supplied name.
 func (s *Set) NewUint64Vec(family string, labels ...string) *Uint64Vec {
-	return &Uint64Vec{getCommonVecSet(s, family, labels)}
+	return &Uint64Vec{commonVec: getCommonVecSet(s, family, labels)}
 }
 
-// NewCounterVec is an alias for [Set.NewUint64Vec].
+// NewCounterVec is an alias for [Set.NewUint64Vec], which marks each Uint64
+// as a counter for [Set.Gather].
 func (s *Set) NewCounterVec(family string, labels ...string) *Uint64Vec {
-	return s.NewUint64Vec(family, labels...)
+	return &Uint64Vec{commonVec: getCommonVecSet(s, family, labels), counter: true}
 }
 
 // A Int64Vec is a collection of Int64s that are partitioned
@@ -83,7 +87,7 @@ func (c *Int64Vec) WithLabelValues(values ...string) *Int64 {
 func (c *Int64Vec) withLabelValues(set *Set, values []string) *Int64 {
 	hash := hashFinish(c.partialHash, values...)
 
-	nm, ok := set.metrics.Load(hash)
+	nm, ok := set.loadMetricFromVec(hash, c.family, c.partialTags, values)
 	if !ok {
 		nm = set.loadOrStoreMetricFromVec(
 			&Int64{}, hash, c.family, c.partialTags, values,
@@ -126,7 +130,7 @@ func (c *Float64Vec) WithLabelValues(values ...string) *Float64 {
 func (c *Float64Vec) withLabelValues(set *Set, values []string) *Float64 {
 	hash := hashFinish(c.partialHash, values...)
 
-	nm, ok := set.metrics.Load(hash)
+	nm, ok := set.loadMetricFromVec(hash, c.family, c.partialTags, values)
 	if !ok {
 		nm = set.loadOrStoreMetricFromVec(
 			&Float64{}, hash, c.family, c.partialTags, values,
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"go.withmatt.com/metrics/internal/fasttime"
+)
+
+// NewDecayingHistogram creates a new DecayingHistogram on the global Set.
+// See [Set.NewDecayingHistogram].
+func NewDecayingHistogram(family string, halfLife time.Duration, buckets []float64, tags ...string) *DecayingHistogram {
+	return defaultSet.NewDecayingHistogram(family, halfLife, buckets, tags...)
+}
+
+// NewDecayingHistogram creates and returns new DecayingHistogram in s with
+// the given name, where observations lose half of their weight every halfLife.
+//
+// family must be a Prometheus compatible identifier format.
+//
+// Optional tags must be specified in [label, value] pairs, for instance,
+//
+//	NewDecayingHistogram("family", time.Minute, []float64{0.1, 0.5, 1}, "label1", "value1")
+//
+// The returned DecayingHistogram is safe to use from concurrent goroutines.
+//
+// This will panic if values are invalid or already registered.
+func (s *Set) NewDecayingHistogram(family string, halfLife time.Duration, buckets []float64, tags ...string) *DecayingHistogram {
+	h := newDecayingHistogram(halfLife, buckets)
+	s.mustStoreMetric(h, MetricName{
+		Family: MustIdent(family),
+		Tags:   MustTags(tags...),
+	})
+	return h
+}
+
+// DecayingHistogram is a Prometheus-like histogram with fixed buckets where
+// the weight of each observation decays exponentially over time, making it a
+// gauge histogram that favors recent observations. Bucket counts, the count
+// and the sum are all weighted, and therefore written as floats.
+//
+// Decay is applied with 1 second granularity.
+//
+// Unlike [FixedHistogram], updates are serialized with a mutex.
+type DecayingHistogram struct {
+	buckets  []float64
+	labels   []string
+	halfLife float64
+
+	mu   sync.Mutex
+	last fasttime.Instant
+	// counts are the weighted counts per bucket, not cumulative, with an
+	// extra trailing bucket for observations above the largest bound.
+	counts []float64
+	sum    float64
+}
+
+func newDecayingHistogram(halfLife time.Duration, buckets []float64) *DecayingHistogram {
+	if halfLife <= 0 {
+		panic("metrics: halfLife must be positive")
+	}
+	buckets = getBuckets(buckets)
+	return &DecayingHistogram{
+		buckets:  buckets,
+		labels:   labelsForBuckets(buckets),
+		halfLife: halfLife.Seconds(),
+		last:     fastClock().Now(),
+		counts:   make([]float64, len(buckets)+1),
+	}
+}
+
+// Update updates h with val.
+//
+// NaNs are ignored.
+func (h *DecayingHistogram) Update(val float64) {
+	if math.IsNaN(val) {
+		return
+	}
+
+	n := findBucket(h.buckets, val)
+
+	h.mu.Lock()
+	h.decay()
+	h.counts[n]++
+	h.sum += val
+	h.mu.Unlock()
+}
+
+// Observe updates h with val, identical to [DecayingHistogram.Update].
+//
+// NaNs are ignored.
+func (h *DecayingHistogram) Observe(val float64) {
+	h.Update(val)
+}
+
+// UpdateDuration updates request duration based on the given startTime.
+func (h *DecayingHistogram) UpdateDuration(startTime time.Time) {
+	h.Update(time.Since(startTime).Seconds())
+}
+
+// Reset resets the given histogram.
+func (h *DecayingHistogram) Reset() {
+	h.mu.Lock()
+	clear(h.counts)
+	h.sum = 0
+	h.last = fastClock().Now()
+	h.mu.Unlock()
+}
+
+// Quantile estimates the q-quantile of the weighted observations using
+// linear interpolation. See [FixedHistogramSnapshot.Quantile].
+func (h *DecayingHistogram) Quantile(q float64) float64 {
+	counts, _, total := h.cumulative()
+	return bucketQuantile(
+		q, h.buckets,
+		func(i int) float64 { return counts[i] },
+		total,
+	)
+}
+
+// decay applies the decay since the last time it was applied, must be
+// called with the lock held.
+func (h *DecayingHistogram) decay() {
+	now := fastClock().Now()
+	elapsed := now.Sub(h.last)
+	if elapsed <= 0 {
+		return
+	}
+	h.last = now
+
+	factor := math.Exp2(-elapsed.Seconds() / h.halfLife)
+	for i := range h.counts {
+		h.counts[i] *= factor
+	}
+	h.sum *= factor
+}
+
+// cumulative returns the decayed cumulative counts for each bucket, along
+// with the decayed sum and total count.
+func (h *DecayingHistogram) cumulative() (counts []float64, sum, total float64) {
+	counts = make([]float64, len(h.counts))
+
+	h.mu.Lock()
+	h.decay()
+	copy(counts, h.counts)
+	sum = h.sum
+	h.mu.Unlock()
+
+	for i := 1; i < len(counts); i++ {
+		counts[i] += counts[i-1]
+	}
+	return counts, sum, counts[len(counts)-1]
+}
+
+func (h *DecayingHistogram) marshalTo(w ExpfmtWriter, name MetricName) {
+	counts, sum, total := h.cumulative()
+	writeFixedHistogram(
+		w, name, h.labels,
+		func(i int) float64 { return counts[i] },
+		total, sum, total,
+	)
+}
@@ -36,6 +36,16 @@ func (t Tag) String() string {
 	return t.label.String() + `="` + t.value.v + `"`
 }
 
+// Label returns the label of the Tag.
+func (t Tag) Label() Label {
+	return t.label
+}
+
+// Value returns the value of the Tag, escaped as it is written.
+func (t Tag) Value() Value {
+	return t.value
+}
+
 // Value represents a Tag value that has been validated as a correct string.
 type Value struct {
 	v string
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var expvarKeyLabel = MustLabel("key")
+
+// NewExpvarCollector is a [MetricCollector] that yields the [expvar] variables with
+// the given names, such as those published by [expvar.NewInt].
+//
+// Each variable is written as a metric of the same name, with characters
+// that are invalid in a metric name replaced by underscores. Numeric
+// variables are written as a single metric, and an [expvar.Map], or any
+// variable whose value is a JSON object, is written as one metric per
+// numeric entry, tagged with its key as `key`. Other values, and variables
+// that are not published, are skipped. Variables are gauges, unless their
+// name ends in _total.
+func NewExpvarCollector(names ...string) Collector {
+	vars := make([]expvarMetric, len(names))
+	for i, name := range names {
+		family := sanitizeIdent(name)
+		vars[i] = expvarMetric{
+			name:    name,
+			family:  MustIdent(family),
+			counter: strings.HasSuffix(family, "_total"),
+		}
+	}
+	return &expvarCollector{vars: vars}
+}
+
+type expvarCollector struct {
+	vars []expvarMetric
+}
+
+type expvarMetric struct {
+	name   string
+	family Ident
+	// counter is set for families ending in _total
+	counter bool
+}
+
+func (c *expvarCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *expvarCollector) CollectMetrics(w MetricWriter) {
+	for _, v := range c.vars {
+		switch ev := expvar.Get(v.name).(type) {
+		case nil:
+		case *expvar.Int:
+			if v.counter && ev.Value() >= 0 {
+				w.WriteCounterUint64(MetricName{Family: v.family}, uint64(ev.Value()))
+			} else {
+				w.WriteGaugeInt64(MetricName{Family: v.family}, ev.Value())
+			}
+		case *expvar.Float:
+			v.write(w, MetricName{Family: v.family}, ev.Value())
+		case *expvar.Map:
+			ev.Do(func(kv expvar.KeyValue) {
+				var val float64
+				switch mv := kv.Value.(type) {
+				case *expvar.Int:
+					val = float64(mv.Value())
+				case *expvar.Float:
+					val = mv.Value()
+				default:
+					var ok bool
+					if val, ok = parseExpvarNumber(mv.String()); !ok {
+						return
+					}
+				}
+				v.write(w, v.keyName(kv.Key), val)
+			})
+		default:
+			v.collectJSON(w, ev.String())
+		}
+	}
+}
+
+// collectJSON writes a variable from its JSON representation.
+func (v expvarMetric) collectJSON(w MetricWriter, s string) {
+	if val, ok := parseExpvarNumber(s); ok {
+		v.write(w, MetricName{Family: v.family}, val)
+		return
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal([]byte(s), &obj) != nil {
+		return
+	}
+	// sort keys for stable output, matching expvar.Map
+	for _, k := range slices.Sorted(maps.Keys(obj)) {
+		if val, ok := parseExpvarNumber(string(obj[k])); ok {
+			v.write(w, v.keyName(k), val)
+		}
+	}
+}
+
+// write writes a value of the variable, as a counter if named like one.
+func (v expvarMetric) write(w MetricWriter, name MetricName, val float64) {
+	if v.counter {
+		w.WriteCounterFloat64(name, val)
+	} else {
+		w.WriteGaugeFloat64(name, val)
+	}
+}
+
+func (v expvarMetric) keyName(key string) MetricName {
+	return MetricName{
+		Family: v.family,
+		Tags:   []Tag{NewTag(expvarKeyLabel, SanitizeValue(key))},
+	}
+}
+
+// parseExpvarNumber parses a JSON number.
+func parseExpvarNumber(s string) (float64, bool) {
+	if s == "" || s[0] != '-' && (s[0] < '0' || s[0] > '9') {
+		return 0, false
+	}
+	val, err := strconv.ParseFloat(s, 64)
+	return val, err == nil
+}
+
+// sanitizeIdent replaces characters that are invalid in an identifier
+// with underscores.
+func sanitizeIdent(s string) string {
+	if s == "" {
+		return "_"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if !isAlpha(c) && !isSymbol(c) && (i == 0 || !isNumeric(c)) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// ExpvarFunc returns an [expvar.Func] exposing the metrics of set, for
+// publishing alongside other variables under /debug/vars. For example:
+//
+//	expvar.Publish("metrics", metrics.ExpvarFunc(set))
+//
+// The value is a JSON object of each series, such as `foo{a="b"}`, to its
+// value. Non-finite values are represented as strings, since they are not
+// valid JSON numbers.
+func ExpvarFunc(set *Set) expvar.Func {
+	return func() any {
+		var b bytes.Buffer
+		set.WritePrometheusUnthrottled(&b)
+
+		out := make(map[string]any)
+		for line := range bytes.Lines(b.Bytes()) {
+			line = bytes.TrimSuffix(line, []byte("\n"))
+			if len(line) == 0 || line[0] == '#' {
+				continue
+			}
+			n := bytes.LastIndexByte(line, ' ')
+			if n < 0 {
+				continue
+			}
+			series, value := string(line[:n]), string(line[n+1:])
+			if val, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(val, 0) && !math.IsNaN(val) {
+				out[series] = val
+			} else {
+				out[series] = value
+			}
+		}
+		return out
+	}
+}
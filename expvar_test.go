@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"math"
+	"sync"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+// publishTestExpvars publishes the expvars of TestExpvarCollector once, since
+// expvar panics when a name is published again, such as with -count.
+var publishTestExpvars = sync.OnceFunc(func() {
+	expvar.NewInt("test-expvar-int").Set(5)
+	expvar.NewFloat("test_expvar_float").Set(1.5)
+	expvar.NewInt("test_expvar_total").Set(2)
+	m := expvar.NewMap("test_expvar_map")
+	m.Add("b", 2)
+	m.AddFloat("a", 0.5)
+	m.Set("skipped", expvar.Func(func() any { return "text" }))
+	expvar.Publish("test_expvar_func", expvar.Func(func() any {
+		return map[string]any{"x": 1, "y": "nope", "z": 3.5}
+	}))
+	expvar.Publish("test_expvar_string", expvar.Func(func() any { return "text" }))
+})
+
+func TestExpvarCollector(t *testing.T) {
+	publishTestExpvars()
+
+	set := NewSet()
+	set.RegisterCollector(NewExpvarCollector(
+		"test-expvar-int",
+		"test_expvar_float",
+		"test_expvar_map",
+		"test_expvar_func",
+		"test_expvar_string",
+		"test_expvar_missing",
+	))
+	assertMarshal(t, set, []string{
+		`test_expvar_int 5`,
+		`test_expvar_float 1.5`,
+		`test_expvar_map{key="a"} 0.5`,
+		`test_expvar_map{key="b"} 2`,
+		`test_expvar_func{key="x"} 1`,
+		`test_expvar_func{key="z"} 3.5`,
+	})
+}
+
+func TestExpvarCollectorTypes(t *testing.T) {
+	publishTestExpvars()
+
+	var r recordingWriter
+	NewExpvarCollector("test-expvar-int", "test_expvar_total").(MetricCollector).CollectMetrics(&r)
+	assert.SlicesEqual(t, r.lines, []string{
+		"gauge test_expvar_int 5",
+		"counter test_expvar_total 2",
+	})
+}
+
+func TestSanitizeIdent(t *testing.T) {
+	for in, want := range map[string]string{
+		"":          "_",
+		"ok_name:1": "ok_name:1",
+		"1abc":      "_abc",
+		"a-b.c d":   "a_b.c_d",
+	} {
+		assert.Equal(t, sanitizeIdent(in), want)
+	}
+}
+
+func TestExpvarFunc(t *testing.T) {
+	set := NewSet()
+	set.NewUint64("foo", "a", "b").Add(3)
+	set.NewFloat64("bar").Set(math.Inf(1))
+
+	var got map[string]any
+	assert.Nil(t, json.Unmarshal([]byte(ExpvarFunc(set).String()), &got))
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[`foo{a="b"}`], any(float64(3)))
+	assert.Equal(t, got["bar"], any("+Inf"))
+}
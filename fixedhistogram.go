@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"bytes"
 	"math"
 	"slices"
 	"strconv"
@@ -86,7 +87,7 @@ func (h *FixedHistogram) Update(val float64) {
 		return
 	}
 
-	n := h.findBucket(val)
+	n := findBucket(h.buckets, val)
 	for ; n < len(h.buckets); n++ {
 		h.observations[n].Add(1)
 	}
@@ -110,42 +111,207 @@ func (h *FixedHistogram) Observe(val float64) {
 	h.Update(val)
 }
 
+// Compatible reports whether h and other share an identical bucket layout,
+// which is required for [FixedHistogram.Merge] and [FixedHistogramSnapshot.Sub].
+func (h *FixedHistogram) Compatible(other *FixedHistogram) bool {
+	return slices.Equal(h.buckets, other.buckets)
+}
+
+// Merge merges src to h.
+//
+// This will panic if src does not have the same buckets as h,
+// see [FixedHistogram.Compatible].
+func (h *FixedHistogram) Merge(src *FixedHistogram) {
+	if !h.Compatible(src) {
+		panic("metrics: cannot merge FixedHistograms with different buckets")
+	}
+
+	for i := range src.observations {
+		h.observations[i].Add(src.observations[i].Load())
+	}
+	h.upper.Add(src.upper.Load())
+	h.sumInt.Add(src.sumInt.Load())
+	h.sumFloat.Add(src.sumFloat.Load())
+	h.count.Add(src.count.Load())
+}
+
+// Snapshot returns a point in time copy of h.
+//
+// Values are loaded individually, so a Snapshot taken while h is being
+// concurrently updated may be slightly inconsistent between buckets.
+func (h *FixedHistogram) Snapshot() FixedHistogramSnapshot {
+	counts := make([]uint64, len(h.observations))
+	for i := range h.observations {
+		counts[i] = h.observations[i].Load()
+	}
+	return FixedHistogramSnapshot{
+		Buckets: h.buckets,
+		Counts:  counts,
+		Inf:     h.upper.Load(),
+		Count:   h.count.Load(),
+		Sum:     h.sum(),
+	}
+}
+
+// FixedHistogramSnapshot is a point in time copy of a [FixedHistogram].
+type FixedHistogramSnapshot struct {
+	// Buckets are the upper bounds of each bucket, shared with the
+	// originating FixedHistogram and must not be modified.
+	Buckets []float64
+	// Counts are the cumulative counts for each bucket in Buckets.
+	Counts []uint64
+	// Inf is the cumulative count for the implicit +Inf bucket.
+	Inf uint64
+	// Count is the total number of observations.
+	Count uint64
+	// Sum is the sum of all observations.
+	Sum float64
+}
+
+// Compatible reports whether s and other share an identical bucket layout.
+func (s FixedHistogramSnapshot) Compatible(other FixedHistogramSnapshot) bool {
+	return slices.Equal(s.Buckets, other.Buckets)
+}
+
+// Sub returns the delta between s and an earlier snapshot prev of the same
+// FixedHistogram, suitable for exporters expecting delta temporality.
+//
+// If any count in s is lower than in prev, the FixedHistogram is assumed to
+// have been reset in between and s is returned as-is.
+//
+// This will panic if prev does not have the same buckets as s.
+func (s FixedHistogramSnapshot) Sub(prev FixedHistogramSnapshot) FixedHistogramSnapshot {
+	if !s.Compatible(prev) {
+		panic("metrics: cannot subtract FixedHistogramSnapshots with different buckets")
+	}
+
+	if s.Count < prev.Count || s.Inf < prev.Inf {
+		return s
+	}
+	counts := make([]uint64, len(s.Counts))
+	for i := range s.Counts {
+		if s.Counts[i] < prev.Counts[i] {
+			return s
+		}
+		counts[i] = s.Counts[i] - prev.Counts[i]
+	}
+
+	return FixedHistogramSnapshot{
+		Buckets: s.Buckets,
+		Counts:  counts,
+		Inf:     s.Inf - prev.Inf,
+		Count:   s.Count - prev.Count,
+		Sum:     s.Sum - prev.Sum,
+	}
+}
+
+// Quantile estimates the q-quantile (0 <= q <= 1) from the buckets of s
+// using linear interpolation, the same way as Prometheus'
+// histogram_quantile() function.
+//
+// Returns NaN if s has no observations.
+func (s FixedHistogramSnapshot) Quantile(q float64) float64 {
+	return bucketQuantile(
+		q, s.Buckets,
+		func(i int) float64 { return float64(s.Counts[i]) },
+		float64(s.Inf),
+	)
+}
+
+// bucketQuantile estimates the q-quantile from the given buckets, where
+// cumulative returns the cumulative count of the bucket at index i and total
+// is the count including the +Inf bucket.
+func bucketQuantile(q float64, buckets []float64, cumulative func(i int) float64, total float64) float64 {
+	switch {
+	case total == 0 || math.IsNaN(q) || len(buckets) == 0:
+		return math.NaN()
+	case q < 0:
+		return math.Inf(-1)
+	case q > 1:
+		return math.Inf(1)
+	}
+
+	rank := q * total
+	i := 0
+	for i < len(buckets) && cumulative(i) < rank {
+		i++
+	}
+
+	switch {
+	case i == len(buckets):
+		// the quantile falls within the +Inf bucket, so the best
+		// we can do is the largest known bound
+		return buckets[i-1]
+	case i == 0 && buckets[0] <= 0:
+		return buckets[0]
+	}
+
+	var lower, prev float64
+	if i > 0 {
+		lower = buckets[i-1]
+		prev = cumulative(i - 1)
+	}
+	count := cumulative(i) - prev
+	if count == 0 {
+		return lower
+	}
+	return lower + (buckets[i]-lower)*((rank-prev)/count)
+}
+
 // UpdateDuration updates request duration based on the given startTime.
 func (h *FixedHistogram) UpdateDuration(startTime time.Time) {
 	h.Update(time.Since(startTime).Seconds())
 }
 
-func (h *FixedHistogram) findBucket(v float64) int {
-	n := len(h.buckets)
+// findBucket returns the index of the first bucket bound v is less than or
+// equal to, or len(buckets) if v is greater than all of them.
+func findBucket(buckets []float64, v float64) int {
+	n := len(buckets)
 	switch {
 	case n == 0:
 		return 0
-	case v < h.buckets[0]:
+	case v < buckets[0]:
 		return 0
-	case v > h.buckets[n-1]:
+	case v > buckets[n-1]:
 		return n
 	case n < 35:
 		// For small arrays, use simple linear search
 		// "magic number" 35 is result of tests on couple different (AWS and bare metal) servers
 		// see more details here: https://github.com/prometheus/client_golang/pull/1662
-		for i, bound := range h.buckets {
+		for i, bound := range buckets {
 			if v <= bound {
 				return i
 			}
 		}
-		// If v is greater than all upper bounds, return len(h.upperBounds)
+		// If v is greater than all upper bounds, return len(buckets)
 		return n
 	}
 
 	// For larger arrays, use stdlib's binary search
-	i, _ := slices.BinarySearch(h.buckets, v)
+	i, _ := slices.BinarySearch(buckets, v)
 	return i
 }
 
 func (h *FixedHistogram) marshalTo(w ExpfmtWriter, name MetricName) {
-	sum := h.sum()
-	count := h.count.Load()
-	upper := h.upper.Load()
+	writeFixedHistogram(
+		w, name, h.labels,
+		func(i int) uint64 { return h.observations[i].Load() },
+		h.upper.Load(), h.sum(), h.count.Load(),
+	)
+}
+
+// writeFixedHistogram writes out `le` style buckets where labels are the
+// formatted bucket bounds, and bucket returns the cumulative count for the
+// bucket at index i. Counts may be floats for weighted histograms.
+func writeFixedHistogram[T uint64 | float64](
+	w ExpfmtWriter,
+	name MetricName,
+	labels []string,
+	bucket func(i int) T,
+	upper T,
+	sum float64,
+	count T,
+) {
 	family := name.Family.String()
 
 	// 1 extra because we're always adding in the vmrange tag
@@ -163,19 +329,19 @@ func (h *FixedHistogram) marshalTo(w ExpfmtWriter, name MetricName) {
 	b := w.b
 
 	b.Grow(
-		(len(family) * len(h.buckets)) +
-			(tagsSize * len(h.buckets)) +
-			(len(chunkLe) * len(h.buckets)) +
+		(len(family) * len(labels)) +
+			(tagsSize * len(labels)) +
+			(len(chunkLe) * len(labels)) +
 			len(family) + len(chunkUpper) + tagsSize + 3 +
 			len(family) + len(chunkSum) + tagsSize + 3 +
 			len(family) + len(chunkCount) + tagsSize + 3 +
 			64, // extra margin of error
 	)
 
-	for i := range h.buckets {
+	for i := range labels {
 		b.WriteString(family)
 		b.WriteString(chunkLe)
-		b.WriteString(h.labels[i])
+		b.WriteString(labels[i])
 		b.WriteByte('"')
 		if len(w.constantTags) > 0 {
 			b.WriteByte(',')
@@ -186,7 +352,7 @@ func (h *FixedHistogram) marshalTo(w ExpfmtWriter, name MetricName) {
 			writeTag(b, tag)
 		}
 		b.WriteString(`} `)
-		writeUint64(b, h.observations[i].Load())
+		writeCount(b, bucket(i))
 		b.WriteByte('\n')
 	}
 
@@ -202,7 +368,7 @@ func (h *FixedHistogram) marshalTo(w ExpfmtWriter, name MetricName) {
 		writeTag(b, tag)
 	}
 	b.WriteString(`} `)
-	writeUint64(b, upper)
+	writeCount(b, upper)
 	b.WriteByte('\n')
 
 	// Write our `_sum` line
@@ -230,10 +396,19 @@ func (h *FixedHistogram) marshalTo(w ExpfmtWriter, name MetricName) {
 		b.WriteByte('}')
 	}
 	b.WriteByte(' ')
-	writeUint64(b, count)
+	writeCount(b, count)
 	b.WriteByte('\n')
 }
 
+func writeCount[T uint64 | float64](b *bytes.Buffer, v T) {
+	switch v := any(v).(type) {
+	case uint64:
+		writeUint64(b, v)
+	case float64:
+		writeFloat64(b, v)
+	}
+}
+
 func (h *FixedHistogram) sum() float64 {
 	return float64(h.sumInt.Load()) + h.sumFloat.Load()
 }
@@ -182,3 +182,78 @@ func TestFixedHistogramConcurrent(t *testing.T) {
 		`x_count 40`,
 	})
 }
+
+func TestFixedHistogramMerge(t *testing.T) {
+	set := NewSet()
+	h := set.NewFixedHistogram("hist", []float64{1, 10})
+	h.Update(0.5)
+	h.Update(5)
+
+	src := newFixedHistogram([]float64{1, 10})
+	src.Update(0.5)
+	src.Update(2.5)
+	src.Update(100)
+
+	h.Merge(src)
+	assertMarshal(t, set, []string{
+		`hist_bucket{le="1"} 2`,
+		`hist_bucket{le="10"} 4`,
+		`hist_bucket{le="+Inf"} 5`,
+		`hist_sum 108.5`,
+		`hist_count 5`,
+	})
+
+	assert.False(t, h.Compatible(newFixedHistogram([]float64{1, 5})))
+	assert.Panics(t, func() { h.Merge(newFixedHistogram([]float64{1, 5})) })
+}
+
+func TestFixedHistogramSnapshotSub(t *testing.T) {
+	h := newFixedHistogram([]float64{1, 10})
+	h.Update(0.5)
+	h.Update(5)
+	prev := h.Snapshot()
+
+	h.Update(0.5)
+	h.Update(50)
+	cur := h.Snapshot()
+
+	delta := cur.Sub(prev)
+	assert.SlicesEqual(t, delta.Buckets, []float64{1, 10})
+	assert.SlicesEqual(t, delta.Counts, []uint64{1, 1})
+	assert.Equal(t, delta.Inf, 2)
+	assert.Equal(t, delta.Count, 2)
+	assert.Equal(t, delta.Sum, 50.5)
+
+	// a reset in between is treated as a fresh start
+	h.Reset()
+	h.Update(5)
+	delta = h.Snapshot().Sub(cur)
+	assert.SlicesEqual(t, delta.Counts, []uint64{0, 1})
+	assert.Equal(t, delta.Count, 1)
+	assert.Equal(t, delta.Sum, 5)
+
+	assert.Panics(t, func() {
+		cur.Sub(newFixedHistogram([]float64{1, 5}).Snapshot())
+	})
+}
+
+func TestFixedHistogramSnapshotQuantile(t *testing.T) {
+	h := newFixedHistogram([]float64{1, 2, 4})
+	assert.True(t, math.IsNaN(h.Snapshot().Quantile(0.5)))
+
+	for range 10 {
+		h.Update(0.5)
+		h.Update(1.5)
+	}
+	s := h.Snapshot()
+	assert.Equal(t, s.Quantile(0.25), 0.5)
+	assert.Equal(t, s.Quantile(0.5), 1)
+	assert.Equal(t, s.Quantile(0.75), 1.5)
+	assert.Equal(t, s.Quantile(1), 2)
+	assert.Equal(t, s.Quantile(-1), math.Inf(-1))
+	assert.Equal(t, s.Quantile(2), math.Inf(1))
+
+	// observations above the largest bound
+	h.Update(100)
+	assert.Equal(t, h.Snapshot().Quantile(1), 4)
+}
@@ -35,7 +35,7 @@ func (h *FixedHistogramVec) WithLabelValues(values ...string) *FixedHistogram {
 func (h *FixedHistogramVec) withLabelValues(set *Set, values []string) *FixedHistogram {
 	hash := hashFinish(h.partialHash, values...)
 
-	nm, ok := set.metrics.Load(hash)
+	nm, ok := set.loadMetricFromVec(hash, h.family, h.partialTags, values)
 	if !ok {
 		nm = set.loadOrStoreMetricFromVec(
 			&FixedHistogram{
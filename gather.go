@@ -0,0 +1,777 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricType is the type of a [MetricFamily].
+type MetricType uint8
+
+const (
+	// TypeUnknown is a family of an unknown type.
+	TypeUnknown MetricType = iota
+	// TypeCounter is a family of monotonically increasing values.
+	TypeCounter
+	// TypeGauge is a family of values that can go up and down.
+	TypeGauge
+	// TypeHistogram is a family of histograms, with either `le` or
+	// `vmrange` buckets.
+	TypeHistogram
+	// TypeSummary is a family of quantiles, such as a [Sketch].
+	TypeSummary
+)
+
+func (t MetricType) String() string {
+	switch t {
+	case TypeCounter:
+		return "counter"
+	case TypeGauge:
+		return "gauge"
+	case TypeHistogram:
+		return "histogram"
+	case TypeSummary:
+		return "summary"
+	default:
+		return "unknown"
+	}
+}
+
+// MetricFamily is a structured snapshot of all series of a family.
+type MetricFamily struct {
+	// Name is the family name, without the _bucket, _sum and _count
+	// suffixes of histograms and summaries.
+	Name string
+	Type MetricType
+	// Unit is from a `# UNIT` comment, or the unit suffix of Name, such as
+	// "seconds" or "bytes". Empty if unknown.
+	Unit string
+	// Help is from a `# HELP` comment. Empty if unknown.
+	Help    string
+	Samples []Sample
+}
+
+// Sample is a single series of a [MetricFamily].
+type Sample struct {
+	// Tags are all the tags the series is written with, including constant
+	// tags, but without the `le`, `vmrange` or `quantile` of buckets.
+	Tags []Tag
+	// Value is the value of a counter, gauge or unknown type.
+	Value SampleValue
+	// Count and Sum are the observations of a histogram or summary. Counts
+	// are floats as the observations of a [DecayingHistogram] are weighted.
+	Count float64
+	Sum   float64
+	// Buckets are the `le` buckets of a histogram, with cumulative counts.
+	Buckets []Bucket
+	// Ranges are the `vmrange` buckets of a [Histogram], with the count of
+	// each range. Only ranges with observations are included.
+	Ranges []RangeBucket
+	// Quantiles are the quantiles of a summary.
+	Quantiles []Quantile
+}
+
+// SampleValue is the value of a counter or gauge, kept as the kind of number
+// it was written as, so integers aren't rounded to a float64.
+type SampleValue struct {
+	kind MetricKind
+	bits uint64
+}
+
+func uint64Value(v uint64) SampleValue {
+	return SampleValue{kind: KindUint64, bits: v}
+}
+
+func int64Value(v int64) SampleValue {
+	return SampleValue{kind: KindInt64, bits: uint64(v)}
+}
+
+func float64Value(v float64) SampleValue {
+	return SampleValue{kind: KindFloat64, bits: math.Float64bits(v)}
+}
+
+// Kind returns either [KindUint64], [KindInt64] or [KindFloat64], or
+// [KindUnknown] for the zero SampleValue.
+func (v SampleValue) Kind() MetricKind {
+	return v.kind
+}
+
+// Uint64 returns the value of a [KindUint64], and reports if v is one.
+func (v SampleValue) Uint64() (uint64, bool) {
+	return v.bits, v.kind == KindUint64
+}
+
+// Int64 returns the value of a [KindInt64], and reports if v is one.
+func (v SampleValue) Int64() (int64, bool) {
+	return int64(v.bits), v.kind == KindInt64
+}
+
+// Float64 returns v of any kind as a float64, which rounds integers beyond
+// 2^53.
+func (v SampleValue) Float64() float64 {
+	switch v.kind {
+	case KindUint64:
+		return float64(v.bits)
+	case KindInt64:
+		return float64(int64(v.bits))
+	default:
+		return math.Float64frombits(v.bits)
+	}
+}
+
+// String returns v as it is written in the text exposition format.
+func (v SampleValue) String() string {
+	switch v.kind {
+	case KindUint64:
+		return strconv.FormatUint(v.bits, 10)
+	case KindInt64:
+		return strconv.FormatInt(int64(v.bits), 10)
+	default:
+		var b bytes.Buffer
+		writeFloat64(&b, v.Float64())
+		return b.String()
+	}
+}
+
+// parseValue parses a value of the text exposition, as an integer if it is
+// one.
+func parseValue(s string) (SampleValue, bool) {
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return uint64Value(u), true
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return int64Value(i), true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return float64Value(f), true
+	}
+	return SampleValue{}, false
+}
+
+// Bucket is a cumulative `le` histogram bucket.
+type Bucket struct {
+	UpperBound float64
+	Count      float64
+}
+
+// RangeBucket is a `vmrange` histogram bucket of observations between Lower
+// and Upper.
+type RangeBucket struct {
+	Lower, Upper float64
+	Count        float64
+}
+
+// Quantile is a quantile of a summary.
+type Quantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// units are the suffixes of family names which are inferred as their unit.
+//
+// See https://prometheus.io/docs/practices/naming/#base-units
+var units = [...]string{
+	"seconds", "bytes", "ratio", "cores", "celsius", "meters", "volts",
+	"amperes", "joules", "grams", "percent",
+}
+
+var (
+	leLabel      = MustLabel("le")
+	vmrangeLabel = MustLabel("vmrange")
+)
+
+// Gather returns a structured snapshot of the global Set.
+// See [Set.Gather].
+func Gather() ([]MetricFamily, error) {
+	return defaultSet.Gather()
+}
+
+// Gather returns a structured, format independent, snapshot of the metrics
+// along with all children and Collectors, ordered by family name. The
+// samples of each family are ordered by their tags.
+//
+// Metrics of the Set are read directly with their real type: a [Uint64] is a
+// counter if registered as one, such as with [Set.NewCounter] or
+// [Set.NewCounterVec], or if its family ends in _total. All other values,
+// such as an [Int64], [Float64] and the Func metrics, are gauges.
+//
+// Collectors are run in turn. A [MetricCollector] writes typed values
+// directly, while the output of other Collectors is parsed from the text
+// exposition. Parsed families that aren't declared with a `# TYPE` comment
+// are a counter if their name ends in _total, otherwise a gauge. Collector
+// output that can't be parsed is skipped, and reported in the returned error
+// along with the errors of any [ContextCollector], and all families that
+// could be gathered.
+func (s *Set) Gather() ([]MetricFamily, error) {
+	if s.isExpired() {
+		return nil, ErrSetExpired
+	}
+
+	g := familyGatherer{bb: getScrapeBuffer(0)}
+	defer putScrapeBuffer(g.bb)
+
+	err := g.gatherSet(context.Background(), s, nil)
+	return g.families(), errors.Join(append(g.errs, err)...)
+}
+
+// familyGatherer builds MetricFamilies from the metrics of a Set tree, typed
+// values of MetricCollectors, and lines of text exposition of other
+// Collectors.
+type familyGatherer struct {
+	byName map[string]*MetricFamily
+	names  []string
+	// meta are the types, units and help from comments by family
+	meta map[string]*MetricFamily
+	errs []error
+	// bb is the buffer text exposition is collected into
+	bb *bytes.Buffer
+}
+
+// gatherSet gathers the metrics of s, its children and Collectors, where
+// prefix are the constant tags of the Set s is registered on as a
+// Collector, if any. The errors of the Collectors are returned joined.
+func (g *familyGatherer) gatherSet(ctx context.Context, s *Set, prefix []Tag) error {
+	constantTags := s.constantTagList
+	if len(prefix) > 0 {
+		constantTags = slices.Concat(prefix, s.constantTagList)
+	}
+
+	for _, nm := range s.metrics.Values() {
+		// yield the scheduler for each metric to not starve CPU
+		runtime.Gosched()
+		g.gatherMetric(nm, slices.Concat(constantTags, nm.name.Tags))
+	}
+
+	var errs []error
+	s.rangeChildrenSets(func(child *Set) bool {
+		errs = append(errs, g.gatherSet(ctx, child, nil))
+		return true
+	})
+
+	errs = append(errs, g.gatherCollectors(ctx, s, constantTags))
+	return errors.Join(errs...)
+}
+
+// gatherMetric adds the value of a metric of a Set with all its tags.
+func (g *familyGatherer) gatherMetric(nm *namedMetric, tags []Tag) {
+	family := nm.name.Family.String()
+	switch m := nm.metric.(type) {
+	case *Uint64:
+		typ := TypeGauge
+		if nm.counter || strings.HasSuffix(family, "_total") {
+			typ = TypeCounter
+		}
+		g.add(family, typ, Sample{Tags: tags, Value: uint64Value(m.Get())})
+	case *Uint64Func:
+		g.add(family, TypeGauge, Sample{Tags: tags, Value: uint64Value(m.Get())})
+	case *Int64:
+		g.add(family, TypeGauge, Sample{Tags: tags, Value: int64Value(m.Get())})
+	case *Int64Func:
+		g.add(family, TypeGauge, Sample{Tags: tags, Value: int64Value(m.Get())})
+	case *Float64:
+		g.add(family, TypeGauge, Sample{Tags: tags, Value: float64Value(m.Get())})
+	case *Float64Func:
+		g.add(family, TypeGauge, Sample{Tags: tags, Value: float64Value(m.Get())})
+	case *Histogram:
+		g.gatherHistogram(family, tags, m)
+	case *FixedHistogram:
+		g.add(family, TypeHistogram, snapshotSample(tags, m.Snapshot()))
+	case *WindowedHistogram:
+		g.add(family, TypeHistogram, snapshotSample(tags, m.Snapshot()))
+	case *fixedHistogramView:
+		counts := make([]uint64, len(m.buckets))
+		total := m.src.fixedCounts(m.buckets, counts)
+		g.add(family, TypeHistogram, snapshotSample(tags, FixedHistogramSnapshot{
+			Buckets: m.buckets,
+			Counts:  counts,
+			Inf:     total,
+			Count:   total,
+			Sum:     m.src.sum.Load(),
+		}))
+	case *DecayingHistogram:
+		counts, sum, total := m.cumulative()
+		sample := Sample{
+			Tags:    tags,
+			Count:   total,
+			Sum:     sum,
+			Buckets: make([]Bucket, 0, len(counts)+1),
+		}
+		for i, bound := range m.buckets {
+			sample.Buckets = append(sample.Buckets, Bucket{UpperBound: bound, Count: counts[i]})
+		}
+		sample.Buckets = append(sample.Buckets, Bucket{UpperBound: math.Inf(1), Count: total})
+		g.add(family, TypeHistogram, sample)
+	case *Sketch:
+		quantiles, count, sum := m.summary()
+		g.add(family, TypeSummary, Sample{
+			Tags:      tags,
+			Count:     float64(count),
+			Sum:       sum,
+			Quantiles: quantiles,
+		})
+	}
+}
+
+// gatherHistogram adds the `vmrange` buckets of h with observations. Like its
+// text exposition, nothing is added without any observations.
+func (g *familyGatherer) gatherHistogram(family string, tags []Tag, h *Histogram) {
+	card := punchCardPool.Get().(*punchCard)
+	defer func() {
+		clear(card[:])
+		punchCardPool.Put(card)
+	}()
+
+	total, punches := h.punchBuckets(card)
+	if total == 0 {
+		return
+	}
+	sample := Sample{
+		Tags:   tags,
+		Count:  float64(total),
+		Sum:    h.sum.Load(),
+		Ranges: make([]RangeBucket, 0, punches),
+	}
+	for idx, count := range card {
+		if count > 0 {
+			sample.Ranges = append(sample.Ranges, RangeBucket{
+				Lower: bucketBounds[idx],
+				Upper: bucketBounds[idx+1],
+				Count: float64(count),
+			})
+		}
+	}
+	g.add(family, TypeHistogram, sample)
+}
+
+// snapshotSample returns the `le` buckets of a FixedHistogramSnapshot.
+func snapshotSample(tags []Tag, snap FixedHistogramSnapshot) Sample {
+	sample := Sample{
+		Tags:    tags,
+		Count:   float64(snap.Count),
+		Sum:     snap.Sum,
+		Buckets: make([]Bucket, 0, len(snap.Buckets)+1),
+	}
+	for i, bound := range snap.Buckets {
+		sample.Buckets = append(sample.Buckets, Bucket{UpperBound: bound, Count: float64(snap.Counts[i])})
+	}
+	sample.Buckets = append(sample.Buckets, Bucket{UpperBound: math.Inf(1), Count: float64(snap.Inf)})
+	return sample
+}
+
+// metricContextCollector is a MetricCollector which also reports its errors,
+// like a [ContextCollector].
+type metricContextCollector interface {
+	MetricCollector
+	collectMetricsContext(ctx context.Context, w MetricWriter) error
+}
+
+// gatherCollectors runs the Collectors of s in turn, with the constant tags
+// of s, and returns their errors joined.
+func (g *familyGatherer) gatherCollectors(ctx context.Context, s *Set, constantTags []Tag) error {
+	collectors := s.collectors.Load()
+	if collectors == nil {
+		return nil
+	}
+
+	cfg := s.collectConfig.Load()
+	w := familyWriter{g: g, constantTags: constantTags}
+	var errs []error
+	for _, rc := range *collectors {
+		// yield the scheduler for each Collector to not starve CPU
+		runtime.Gosched()
+		began := time.Now()
+
+		c := rc.Collector
+		if nc, ok := c.(*namedCollector); ok {
+			c = nc.Collector
+		}
+		var err error
+		switch c := c.(type) {
+		case *Set:
+			err = rc.reportError(cfg, g.gatherSet(ctx, c, constantTags))
+		case metricContextCollector:
+			err = rc.reportError(cfg, c.collectMetricsContext(ctx, w))
+		case MetricCollector:
+			c.CollectMetrics(w)
+		default:
+			// legacy Collectors only write text, which is parsed back
+			g.bb.Reset()
+			err = rc.collect(ctx, cfg, ExpfmtWriter{
+				b:            g.bb,
+				constantTags: materializeTags(constantTags),
+			})
+			for line := range bytes.Lines(g.bb.Bytes()) {
+				g.parseLine(line)
+			}
+		}
+		rc.duration.Store(time.Since(began).Seconds())
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if cfg != nil && cfg.stats {
+		writeCollectorStats(w, *collectors)
+	}
+	return errors.Join(errs...)
+}
+
+// add adds a sample to the family of name, creating it with typ if needed.
+// The type of a metric is known, so it overrides an inferred type.
+func (g *familyGatherer) add(name string, typ MetricType, sample Sample) {
+	f := g.family(name, typ)
+	f.Type = typ
+	f.Samples = append(f.Samples, sample)
+}
+
+// familyWriter is a MetricWriter which adds typed values to the families of
+// a familyGatherer, with the constant tags prepended to the tags of each
+// metric.
+type familyWriter struct {
+	g            *familyGatherer
+	constantTags []Tag
+}
+
+var _ MetricWriter = familyWriter{}
+
+func (w familyWriter) tags(name MetricName) []Tag {
+	return slices.Concat(w.constantTags, name.Tags)
+}
+
+func (w familyWriter) WriteGaugeUint64(name MetricName, value uint64) {
+	w.g.add(name.Family.String(), TypeGauge, Sample{Tags: w.tags(name), Value: uint64Value(value)})
+}
+
+func (w familyWriter) WriteGaugeInt64(name MetricName, value int64) {
+	w.g.add(name.Family.String(), TypeGauge, Sample{Tags: w.tags(name), Value: int64Value(value)})
+}
+
+func (w familyWriter) WriteGaugeFloat64(name MetricName, value float64) {
+	w.g.add(name.Family.String(), TypeGauge, Sample{Tags: w.tags(name), Value: float64Value(value)})
+}
+
+func (w familyWriter) WriteCounterUint64(name MetricName, value uint64) {
+	w.g.add(name.Family.String(), TypeCounter, Sample{Tags: w.tags(name), Value: uint64Value(value)})
+}
+
+func (w familyWriter) WriteCounterFloat64(name MetricName, value float64) {
+	w.g.add(name.Family.String(), TypeCounter, Sample{Tags: w.tags(name), Value: float64Value(value)})
+}
+
+// WriteHistogram adds the ranges with observations, and like
+// [ExpfmtWriter.WriteHistogram], nothing if there are no observations.
+func (w familyWriter) WriteHistogram(name MetricName, bounds []float64, counts []uint64, sum float64) {
+	if len(bounds) != len(counts)+1 {
+		panic("metrics: histogram must have one more bound than counts")
+	}
+
+	sample := Sample{Tags: w.tags(name), Sum: sum}
+	for i, count := range counts {
+		if count > 0 {
+			sample.Count += float64(count)
+			sample.Ranges = append(sample.Ranges, RangeBucket{
+				Lower: bounds[i],
+				Upper: bounds[i+1],
+				Count: float64(count),
+			})
+		}
+	}
+	if len(sample.Ranges) == 0 {
+		return
+	}
+	w.g.add(name.Family.String(), TypeHistogram, sample)
+}
+
+func (w familyWriter) WriteSummary(name MetricName, quantiles []Quantile, count uint64, sum float64) {
+	w.g.add(name.Family.String(), TypeSummary, Sample{
+		Tags:      w.tags(name),
+		Count:     float64(count),
+		Sum:       sum,
+		Quantiles: slices.Clone(quantiles),
+	})
+}
+
+// parseLine parses a line of text exposition of a Collector, whose type is
+// inferred unless declared by a comment. The lines of each histogram or
+// summary must be contiguous.
+func (g *familyGatherer) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	if line[0] == '#' {
+		g.parseComment(string(line))
+		return
+	}
+
+	name, tags, value, err := parseSample(string(line))
+	if err != nil {
+		g.errs = append(g.errs, err)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(name, "_bucket") && hasLabel(tags, leLabel):
+		le, tags := cutLabel(tags, leLabel)
+		upper, err := strconv.ParseFloat(le, 64)
+		if err != nil {
+			g.errs = append(g.errs, fmt.Errorf("metrics: invalid le %q: %q", le, line))
+			return
+		}
+		sample := g.sample(strings.TrimSuffix(name, "_bucket"), TypeHistogram, tags)
+		sample.Buckets = append(sample.Buckets, Bucket{UpperBound: upper, Count: value.Float64()})
+
+	case strings.HasSuffix(name, "_bucket") && hasLabel(tags, vmrangeLabel):
+		vmrange, tags := cutLabel(tags, vmrangeLabel)
+		lower, upper, ok := parseVMRange(vmrange)
+		if !ok {
+			g.errs = append(g.errs, fmt.Errorf("metrics: invalid vmrange %q: %q", vmrange, line))
+			return
+		}
+		sample := g.sample(strings.TrimSuffix(name, "_bucket"), TypeHistogram, tags)
+		sample.Ranges = append(sample.Ranges, RangeBucket{Lower: lower, Upper: upper, Count: value.Float64()})
+
+	case hasLabel(tags, quantileLabel):
+		q, tags := cutLabel(tags, quantileLabel)
+		quantile, err := strconv.ParseFloat(q, 64)
+		if err != nil {
+			g.errs = append(g.errs, fmt.Errorf("metrics: invalid quantile %q: %q", q, line))
+			return
+		}
+		sample := g.sample(name, TypeSummary, tags)
+		sample.Quantiles = append(sample.Quantiles, Quantile{Quantile: quantile, Value: value.Float64()})
+
+	default:
+		if base, ok := strings.CutSuffix(name, "_sum"); ok && g.isDistribution(base) {
+			g.sample(base, g.declaredType(base), tags).Sum = value.Float64()
+			return
+		}
+		if base, ok := strings.CutSuffix(name, "_count"); ok && g.isDistribution(base) {
+			g.sample(base, g.declaredType(base), tags).Count = value.Float64()
+			return
+		}
+		typ := g.declaredType(name)
+		if typ != TypeCounter && typ != TypeGauge {
+			typ = TypeGauge
+			if strings.HasSuffix(name, "_total") {
+				typ = TypeCounter
+			}
+		}
+		f := g.family(name, typ)
+		f.Samples = append(f.Samples, Sample{Tags: tags, Value: value})
+	}
+}
+
+// parseComment parses # HELP, # TYPE and # UNIT comments. Other comments
+// are ignored.
+func (g *familyGatherer) parseComment(line string) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 4 || fields[0] != "#" {
+		return
+	}
+	if g.meta == nil {
+		g.meta = make(map[string]*MetricFamily)
+	}
+	meta, ok := g.meta[fields[2]]
+	if !ok {
+		meta = &MetricFamily{}
+		g.meta[fields[2]] = meta
+	}
+	switch fields[1] {
+	case "HELP":
+		meta.Help = fields[3]
+	case "UNIT":
+		meta.Unit = fields[3]
+	case "TYPE":
+		switch fields[3] {
+		case "counter":
+			meta.Type = TypeCounter
+		case "gauge":
+			meta.Type = TypeGauge
+		case "histogram":
+			meta.Type = TypeHistogram
+		case "summary":
+			meta.Type = TypeSummary
+		}
+	}
+}
+
+// declaredType returns the type of family declared by a `# TYPE` comment, if
+// any.
+func (g *familyGatherer) declaredType(family string) MetricType {
+	if meta, ok := g.meta[family]; ok {
+		return meta.Type
+	}
+	return TypeUnknown
+}
+
+// isDistribution reports if family is a histogram or summary, whose _sum
+// and _count lines are part of the family.
+func (g *familyGatherer) isDistribution(family string) bool {
+	if f, ok := g.byName[family]; ok && (f.Type == TypeHistogram || f.Type == TypeSummary) {
+		return true
+	}
+	if meta, ok := g.meta[family]; ok && (meta.Type == TypeHistogram || meta.Type == TypeSummary) {
+		return true
+	}
+	return false
+}
+
+// family returns the family of name, creating it with typ if needed.
+func (g *familyGatherer) family(name string, typ MetricType) *MetricFamily {
+	if f, ok := g.byName[name]; ok {
+		return f
+	}
+	if g.byName == nil {
+		g.byName = make(map[string]*MetricFamily)
+	}
+	f := &MetricFamily{Name: name, Type: typ}
+	g.byName[name] = f
+	g.names = append(g.names, name)
+	return f
+}
+
+// sample returns the sample of a histogram or summary family with the tags.
+// Lines of a sample are contiguous, so only the last sample is checked.
+func (g *familyGatherer) sample(name string, typ MetricType, tags []Tag) *Sample {
+	f := g.family(name, typ)
+	if n := len(f.Samples); n > 0 && tagsEqual(f.Samples[n-1].Tags, tags) {
+		return &f.Samples[n-1]
+	}
+	f.Samples = append(f.Samples, Sample{Tags: tags})
+	return &f.Samples[len(f.Samples)-1]
+}
+
+func (g *familyGatherer) families() []MetricFamily {
+	slices.Sort(g.names)
+	families := make([]MetricFamily, len(g.names))
+	for i, name := range g.names {
+		f := g.byName[name]
+		if meta, ok := g.meta[name]; ok {
+			f.Help = meta.Help
+			f.Unit = meta.Unit
+		}
+		slices.SortStableFunc(f.Samples, func(a, b Sample) int {
+			return compareTags(a.Tags, b.Tags)
+		})
+		if f.Unit == "" {
+			f.Unit = inferUnit(name)
+		}
+		families[i] = *f
+	}
+	return families
+}
+
+// inferUnit returns the unit suffix of a family name, if any.
+func inferUnit(family string) string {
+	family = strings.TrimSuffix(family, "_total")
+	for _, unit := range units {
+		if strings.HasSuffix(family, "_"+unit) {
+			return unit
+		}
+	}
+	return ""
+}
+
+// parseSample parses a line of text exposition such as:
+//
+//	family{label="value",...} 1.5 [timestamp]
+func parseSample(line string) (name string, tags []Tag, value SampleValue, err error) {
+	i := strings.IndexAny(line, "{ ")
+	if i < 0 {
+		return "", nil, SampleValue{}, fmt.Errorf("metrics: missing value: %q", line)
+	}
+	name, rest := line[:i], line[i:]
+	if !validateIdent(name) {
+		return "", nil, SampleValue{}, fmt.Errorf("metrics: invalid family: %q", line)
+	}
+
+	if rest[0] == '{' {
+		rest = rest[1:]
+		for {
+			if after, ok := strings.CutPrefix(rest, "}"); ok {
+				rest = after
+				break
+			}
+			var tag Tag
+			var ok bool
+			tag, rest, ok = parseTag(rest)
+			if !ok {
+				return "", nil, SampleValue{}, fmt.Errorf("metrics: invalid tags: %q", line)
+			}
+			tags = append(tags, tag)
+			rest = strings.TrimPrefix(rest, ",")
+		}
+	}
+
+	// an optional timestamp follows the value
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, SampleValue{}, fmt.Errorf("metrics: missing value: %q", line)
+	}
+	value, ok := parseValue(fields[0])
+	if !ok {
+		return "", nil, SampleValue{}, fmt.Errorf("metrics: invalid value: %q", line)
+	}
+	return name, tags, value, nil
+}
+
+// parseTag parses a label="value" pair from the start of s, returning the
+// remainder of s after the closing quote. The value is kept escaped.
+func parseTag(s string) (tag Tag, rest string, ok bool) {
+	label, rest, ok := strings.Cut(s, `="`)
+	if !ok || !validateIdent(label) {
+		return Tag{}, "", false
+	}
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			i++
+		case '"':
+			return Tag{
+				label: MustLabel(label),
+				value: UnsafeValue(rest[:i]),
+			}, rest[i+1:], true
+		}
+	}
+	return Tag{}, "", false
+}
+
+// parseVMRange parses a `vmrange` value such as "1.000e-09...1.136e-09".
+func parseVMRange(s string) (lower, upper float64, ok bool) {
+	l, u, ok := strings.Cut(s, "...")
+	if !ok {
+		return 0, 0, false
+	}
+	lower, err1 := strconv.ParseFloat(l, 64)
+	upper, err2 := strconv.ParseFloat(u, 64)
+	return lower, upper, err1 == nil && err2 == nil
+}
+
+func hasLabel(tags []Tag, label Label) bool {
+	return slices.ContainsFunc(tags, func(t Tag) bool {
+		return t.label.String() == label.String()
+	})
+}
+
+// cutLabel returns the value of label and the remaining tags.
+func cutLabel(tags []Tag, label Label) (string, []Tag) {
+	var value string
+	rest := make([]Tag, 0, len(tags)-1)
+	for _, t := range tags {
+		if t.label.String() == label.String() {
+			value = t.value.v
+		} else {
+			rest = append(rest, t)
+		}
+	}
+	return value, rest
+}
@@ -0,0 +1,319 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestGather(t *testing.T) {
+	set := NewSet("env", "prod")
+	set.NewCounter("requests_total", "code", "200").Add(3)
+	set.NewFloat64("temperature_celsius").Set(21.5)
+	set.NewFixedHistogram("size_bytes", []float64{1, 10}).Update(5)
+	set.NewHistogram("latency_seconds").Update(0.5)
+	set.NewSketch("duration_seconds", 0, []float64{0.5}).Update(2)
+
+	child := set.NewSet("shard", "a")
+	child.NewCounter("requests_total", "code", "200").Inc()
+
+	set.RegisterCollector(CollectorFunc(func(w ExpfmtWriter) {
+		w.WriteLine([]byte("# HELP queue_depth Items waiting.\n"))
+		w.WriteLine([]byte("# TYPE queue_depth gauge\n"))
+		w.WriteLazyMetricUint64("queue_depth", 4)
+		w.WriteLine([]byte("# TYPE events counter\n"))
+		w.WriteLazyMetricUint64("events", 7)
+		w.Buffer().WriteString("bad line\n")
+	}))
+
+	families, err := set.Gather()
+	assert.NotNil(t, err)
+
+	var names []string
+	byName := make(map[string]MetricFamily)
+	for _, f := range families {
+		names = append(names, f.Name)
+		byName[f.Name] = f
+	}
+	assert.SlicesEqual(t, names, []string{
+		"duration_seconds",
+		"events",
+		"latency_seconds",
+		"queue_depth",
+		"requests_total",
+		"size_bytes",
+		"temperature_celsius",
+	})
+
+	f := byName["requests_total"]
+	assert.Equal(t, f.Type, TypeCounter)
+	assert.Equal(t, f.Unit, "")
+	assert.Equal(t, len(f.Samples), 2)
+	assert.Equal(t, materializeTags(f.Samples[0].Tags), `env="prod",code="200"`)
+	assert.Equal(t, f.Samples[0].Value, uint64Value(3))
+	assert.Equal(t, materializeTags(f.Samples[1].Tags), `env="prod",shard="a",code="200"`)
+	assert.Equal(t, f.Samples[1].Value, uint64Value(1))
+
+	f = byName["temperature_celsius"]
+	assert.Equal(t, f.Type, TypeGauge)
+	assert.Equal(t, f.Unit, "celsius")
+	assert.Equal(t, f.Samples[0].Value, float64Value(21.5))
+
+	f = byName["size_bytes"]
+	assert.Equal(t, f.Type, TypeHistogram)
+	assert.Equal(t, f.Unit, "bytes")
+	assert.Equal(t, len(f.Samples), 1)
+	s := f.Samples[0]
+	assert.Equal(t, materializeTags(s.Tags), `env="prod"`)
+	assert.SlicesEqual(t, s.Buckets, []Bucket{{1, 0}, {10, 1}, {math.Inf(1), 1}})
+	assert.Equal(t, s.Sum, 5)
+	assert.Equal(t, s.Count, 1)
+
+	f = byName["latency_seconds"]
+	assert.Equal(t, f.Type, TypeHistogram)
+	s = f.Samples[0]
+	assert.Equal(t, len(s.Ranges), 1)
+	assert.True(t, s.Ranges[0].Lower < 0.5 && 0.5 <= s.Ranges[0].Upper)
+	assert.Equal(t, s.Ranges[0].Count, 1)
+	assert.Equal(t, s.Sum, 0.5)
+	assert.Equal(t, s.Count, 1)
+
+	f = byName["duration_seconds"]
+	assert.Equal(t, f.Type, TypeSummary)
+	s = f.Samples[0]
+	assert.Equal(t, len(s.Quantiles), 1)
+	assert.Equal(t, s.Quantiles[0].Quantile, 0.5)
+	assert.Equal(t, s.Count, 1)
+
+	f = byName["queue_depth"]
+	assert.Equal(t, f.Type, TypeGauge)
+	assert.Equal(t, f.Help, "Items waiting.")
+	assert.Equal(t, materializeTags(f.Samples[0].Tags), `env="prod"`)
+	assert.Equal(t, f.Samples[0].Value, uint64Value(4))
+
+	assert.Equal(t, byName["events"].Type, TypeCounter)
+}
+
+func TestGatherMetricTypes(t *testing.T) {
+	set := NewSet()
+	set.NewCounter("requests").Add(2)
+	set.NewCounterVec("hits", "code").WithLabelValues("200").Inc()
+	set.NewUint64("connections").Set(4)
+	set.NewUint64("retries_total").Inc()
+	set.NewInt64("queue_depth_total").Set(-1)
+	set.NewUint64Func("open_total", func() uint64 { return 3 })
+	set.NewSetVec("shard").NewCounter("jobs", "a")
+	set.RegisterCollector(CollectorFunc(func(w ExpfmtWriter) {
+		w.WriteLazyMetricUint64("collected", 1)
+		w.WriteLazyMetricUint64("collected_total", 2)
+	}))
+
+	families, err := set.Gather()
+	assert.Nil(t, err)
+	types := make(map[string]MetricType)
+	for _, f := range families {
+		types[f.Name] = f.Type
+	}
+	assert.Equal(t, len(types), 9)
+	// metrics of the Set have their real type
+	assert.Equal(t, types["requests"], TypeCounter)
+	assert.Equal(t, types["hits"], TypeCounter)
+	assert.Equal(t, types["jobs"], TypeCounter)
+	assert.Equal(t, types["connections"], TypeGauge)
+	assert.Equal(t, types["retries_total"], TypeCounter)
+	assert.Equal(t, types["queue_depth_total"], TypeGauge)
+	assert.Equal(t, types["open_total"], TypeGauge)
+	// Collector output is inferred from the name
+	assert.Equal(t, types["collected"], TypeGauge)
+	assert.Equal(t, types["collected_total"], TypeCounter)
+}
+
+func TestGatherValues(t *testing.T) {
+	set := NewSet()
+	set.NewUint64("uint").Set(math.MaxUint64)
+	set.NewInt64("int").Set(math.MinInt64)
+	set.NewFloat64("float").Set(0.1)
+	set.RegisterCollector(
+		MetricCollectorFunc(func(w MetricWriter) {
+			w.WriteCounterUint64(NewMetricName("typed_total"), math.MaxUint64-1)
+		}),
+		CollectorFunc(func(w ExpfmtWriter) {
+			w.WriteLazyMetricUint64("parsed", math.MaxUint64-2)
+			w.WriteLazyMetricInt64("parsed_int", -2)
+			w.WriteLazyMetricFloat64("parsed_float", 1.5)
+		}),
+	)
+
+	families, err := set.Gather()
+	assert.Nil(t, err)
+	values := make(map[string]SampleValue)
+	for _, f := range families {
+		values[f.Name] = f.Samples[0].Value
+	}
+
+	// integers are exact, rather than rounded to a float64
+	u, ok := values["uint"].Uint64()
+	assert.True(t, ok)
+	assert.Equal(t, u, uint64(math.MaxUint64))
+	i, ok := values["int"].Int64()
+	assert.True(t, ok)
+	assert.Equal(t, i, int64(math.MinInt64))
+	_, ok = values["int"].Uint64()
+	assert.False(t, ok)
+	assert.Equal(t, values["float"].Kind(), KindFloat64)
+	assert.Equal(t, values["float"].Float64(), 0.1)
+
+	assert.Equal(t, values["typed_total"], uint64Value(math.MaxUint64-1))
+	assert.Equal(t, values["parsed"], uint64Value(math.MaxUint64-2))
+	assert.Equal(t, values["parsed_int"], int64Value(-2))
+	assert.Equal(t, values["parsed_float"], float64Value(1.5))
+
+	assert.Equal(t, values["uint"].String(), "18446744073709551615")
+	assert.Equal(t, values["parsed_int"].String(), "-2")
+	assert.Equal(t, values["parsed_float"].String(), "1.5")
+	assert.Equal(t, values["int"].Float64(), float64(math.MinInt64))
+}
+
+func TestGatherMetricCollector(t *testing.T) {
+	child := NewSet("env", "prod")
+	child.NewUint64("nested").Set(1)
+	child.RegisterCollector(NamedCollector("test_typed", MetricCollectorFunc(func(w MetricWriter) {
+		w.WriteGaugeInt64(NewMetricName("temperature"), -3)
+		w.WriteHistogram(NewMetricName("latency"),
+			[]float64{0, 0.5, 1, 2},
+			[]uint64{2, 0, 1},
+			2.5,
+		)
+		w.WriteHistogram(NewMetricName("empty"), []float64{0, 1}, []uint64{0}, 0)
+		w.WriteSummary(NewMetricName("pause_seconds"), []Quantile{{Quantile: 1, Value: 0.25}}, 4, 0.6)
+	})))
+	child.SetCollectOptions(WithCollectorStats())
+	set := NewSet("region", "x")
+	set.RegisterCollector(child)
+
+	families, err := set.Gather()
+	assert.Nil(t, err)
+	byName := make(map[string]MetricFamily)
+	for _, f := range families {
+		byName[f.Name] = f
+	}
+	assert.Equal(t, len(byName), 7)
+
+	// a Set registered as a Collector is gathered with the constant tags of
+	// both Sets
+	f := byName["nested"]
+	assert.Equal(t, f.Type, TypeGauge)
+	assert.Equal(t, materializeTags(f.Samples[0].Tags), `region="x",env="prod"`)
+
+	f = byName["temperature"]
+	assert.Equal(t, f.Type, TypeGauge)
+	assert.Equal(t, materializeTags(f.Samples[0].Tags), `region="x",env="prod"`)
+	assert.Equal(t, f.Samples[0].Value, int64Value(-3))
+
+	f = byName["latency"]
+	assert.Equal(t, f.Type, TypeHistogram)
+	assert.SlicesEqual(t, f.Samples[0].Ranges, []RangeBucket{{0, 0.5, 2}, {1, 2, 1}})
+	assert.Equal(t, f.Samples[0].Count, 3)
+	assert.Equal(t, f.Samples[0].Sum, 2.5)
+
+	f = byName["pause_seconds"]
+	assert.Equal(t, f.Type, TypeSummary)
+	assert.Equal(t, f.Unit, "seconds")
+	assert.SlicesEqual(t, f.Samples[0].Quantiles, []Quantile{{1, 0.25}})
+	assert.Equal(t, f.Samples[0].Count, 4)
+
+	f = byName["gometrics_collector_errors_total"]
+	assert.Equal(t, f.Type, TypeCounter)
+	assert.Equal(t, materializeTags(f.Samples[0].Tags), `region="x",env="prod",collector="test_typed"`)
+	assert.Equal(t, byName["gometrics_collector_duration_seconds"].Type, TypeGauge)
+}
+
+func TestGatherHistograms(t *testing.T) {
+	set := NewSet()
+	h := set.NewHistogram("latency_seconds")
+	h.Update(0.5)
+	h.Update(0.5)
+	h.Update(1e20)
+	set.NewFixedHistogramView("latency_fixed_seconds", h, []float64{1})
+	set.NewWindowedHistogram("windowed", time.Minute, []float64{1}).Update(2)
+	set.NewDecayingHistogram("decaying", time.Minute, []float64{1}).Update(0.5)
+	set.NewHistogram("unobserved")
+
+	families, err := set.Gather()
+	assert.Nil(t, err)
+	byName := make(map[string]MetricFamily)
+	for _, f := range families {
+		assert.Equal(t, f.Type, TypeHistogram)
+		byName[f.Name] = f
+	}
+	// a Histogram without observations isn't written, nor gathered
+	assert.Equal(t, len(byName), 4)
+
+	// ranges are the bounds of their `vmrange` label
+	var b bytes.Buffer
+	h.marshalTo(ExpfmtWriter{b: &b}, NewMetricName("latency_seconds"))
+	s := byName["latency_seconds"].Samples[0]
+	assert.Equal(t, len(s.Ranges), 2)
+	for _, r := range s.Ranges {
+		vmrange := strconv.FormatFloat(r.Lower, 'e', 3, 64) + "..."
+		if math.IsInf(r.Upper, 1) {
+			vmrange += "+Inf"
+		} else {
+			vmrange += strconv.FormatFloat(r.Upper, 'e', 3, 64)
+		}
+		assert.True(t, strings.Contains(b.String(), `{vmrange="`+vmrange+`"} `), assert.Sprintf("%s", vmrange))
+	}
+	assert.Equal(t, s.Ranges[0].Count, 2)
+	assert.Equal(t, s.Ranges[1].Count, 1)
+	assert.Equal(t, s.Count, 3)
+
+	assert.SlicesEqual(t, byName["latency_fixed_seconds"].Samples[0].Buckets,
+		[]Bucket{{1, 2}, {math.Inf(1), 3}})
+	assert.SlicesEqual(t, byName["windowed"].Samples[0].Buckets,
+		[]Bucket{{1, 0}, {math.Inf(1), 1}})
+	s = byName["decaying"].Samples[0]
+	assert.Equal(t, len(s.Buckets), 2)
+	assert.Greater(t, s.Buckets[0].Count, 0.99)
+	assert.Equal(t, s.Buckets[1].Count, s.Count)
+}
+
+func TestParseSample(t *testing.T) {
+	name, tags, value, err := parseSample(`foo{a="x\"y,}",b=""} 1.5 1700000000`)
+	assert.Nil(t, err)
+	assert.Equal(t, name, "foo")
+	assert.Equal(t, materializeTags(tags), `a="x\"y,}",b=""`)
+	assert.Equal(t, value, float64Value(1.5))
+
+	_, _, value, err = parseSample("foo +Inf")
+	assert.Nil(t, err)
+	assert.True(t, math.IsInf(value.Float64(), 1))
+
+	_, _, value, err = parseSample("foo -1")
+	assert.Nil(t, err)
+	assert.Equal(t, value, int64Value(-1))
+
+	for _, line := range []string{
+		"foo",
+		"foo{",
+		`foo{a="x} 1`,
+		`foo{a=x} 1`,
+		"foo{} abc",
+		"1foo 1",
+	} {
+		_, _, _, err := parseSample(line)
+		assert.NotNil(t, err, assert.Sprintf("%q", line))
+	}
+}
+
+func TestGatherExpired(t *testing.T) {
+	sv := NewSet().NewSetVecWithTTL("a", 1)
+	set := sv.WithLabelValue("x")
+	set.lastUsed.Store(0)
+	_, err := set.Gather()
+	assert.ErrorIs(t, err, ErrSetExpired)
+}
@@ -6,13 +6,7 @@ import (
 	"time"
 )
 
-var quantileTags = [...]Tag{
-	MustTag("quantile", "0"),
-	MustTag("quantile", "0.25"),
-	MustTag("quantile", "0.5"),
-	MustTag("quantile", "0.75"),
-	MustTag("quantile", "1"),
-}
+var gcQuantiles = [...]float64{0, 0.25, 0.5, 0.75, 1}
 
 // NewGoMemstatsCollector is a Collector that yields Go runtime memory statistics
 // from [runtime.ReadMemStats].
@@ -26,37 +20,41 @@ func NewGoMemstatsCollector() Collector {
 type goMemstatsCollector struct{}
 
 func (c *goMemstatsCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *goMemstatsCollector) CollectMetrics(w MetricWriter) {
 	var ms runtime.MemStats
 	runtime.ReadMemStats(&ms)
 	c.collectGCStats(&ms, w)
 
-	w.WriteLazyMetricUint64("go_memstats_alloc_bytes", ms.Alloc)
-	w.WriteLazyMetricUint64("go_memstats_alloc_bytes_total", ms.TotalAlloc)
-	w.WriteLazyMetricUint64("go_memstats_buck_hash_sys_bytes", ms.BuckHashSys)
-	w.WriteLazyMetricUint64("go_memstats_frees_total", ms.Frees)
-	w.WriteLazyMetricFloat64("go_memstats_gc_cpu_fraction", ms.GCCPUFraction)
-	w.WriteLazyMetricUint64("go_memstats_gc_sys_bytes", ms.GCSys)
-	w.WriteLazyMetricUint64("go_memstats_heap_alloc_bytes", ms.HeapAlloc)
-	w.WriteLazyMetricUint64("go_memstats_heap_idle_bytes", ms.HeapIdle)
-	w.WriteLazyMetricUint64("go_memstats_heap_inuse_bytes", ms.HeapInuse)
-	w.WriteLazyMetricUint64("go_memstats_heap_objects", ms.HeapObjects)
-	w.WriteLazyMetricUint64("go_memstats_heap_released_bytes", ms.HeapReleased)
-	w.WriteLazyMetricUint64("go_memstats_heap_sys_bytes", ms.HeapSys)
-	w.WriteLazyMetricDuration("go_memstats_last_gc_time_seconds", time.Duration(ms.LastGC))
-	w.WriteLazyMetricUint64("go_memstats_lookups_total", ms.Lookups)
-	w.WriteLazyMetricUint64("go_memstats_mallocs_total", ms.Mallocs)
-	w.WriteLazyMetricUint64("go_memstats_mcache_inuse_bytes", ms.MCacheInuse)
-	w.WriteLazyMetricUint64("go_memstats_mcache_sys_bytes", ms.MCacheSys)
-	w.WriteLazyMetricUint64("go_memstats_mspan_inuse_bytes", ms.MSpanInuse)
-	w.WriteLazyMetricUint64("go_memstats_mspan_sys_bytes", ms.MSpanSys)
-	w.WriteLazyMetricUint64("go_memstats_next_gc_bytes", ms.NextGC)
-	w.WriteLazyMetricUint64("go_memstats_other_sys_bytes", ms.OtherSys)
-	w.WriteLazyMetricUint64("go_memstats_stack_inuse_bytes", ms.StackInuse)
-	w.WriteLazyMetricUint64("go_memstats_stack_sys_bytes", ms.StackSys)
-	w.WriteLazyMetricUint64("go_memstats_sys_bytes", ms.Sys)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_alloc_bytes"), ms.Alloc)
+	w.WriteCounterUint64(NewMetricName("go_memstats_alloc_bytes_total"), ms.TotalAlloc)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_buck_hash_sys_bytes"), ms.BuckHashSys)
+	w.WriteCounterUint64(NewMetricName("go_memstats_frees_total"), ms.Frees)
+	w.WriteGaugeFloat64(NewMetricName("go_memstats_gc_cpu_fraction"), ms.GCCPUFraction)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_gc_sys_bytes"), ms.GCSys)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_heap_alloc_bytes"), ms.HeapAlloc)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_heap_idle_bytes"), ms.HeapIdle)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_heap_inuse_bytes"), ms.HeapInuse)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_heap_objects"), ms.HeapObjects)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_heap_released_bytes"), ms.HeapReleased)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_heap_sys_bytes"), ms.HeapSys)
+	w.WriteGaugeFloat64(NewMetricName("go_memstats_last_gc_time_seconds"), time.Duration(ms.LastGC).Seconds())
+	w.WriteCounterUint64(NewMetricName("go_memstats_lookups_total"), ms.Lookups)
+	w.WriteCounterUint64(NewMetricName("go_memstats_mallocs_total"), ms.Mallocs)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_mcache_inuse_bytes"), ms.MCacheInuse)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_mcache_sys_bytes"), ms.MCacheSys)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_mspan_inuse_bytes"), ms.MSpanInuse)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_mspan_sys_bytes"), ms.MSpanSys)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_next_gc_bytes"), ms.NextGC)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_other_sys_bytes"), ms.OtherSys)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_stack_inuse_bytes"), ms.StackInuse)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_stack_sys_bytes"), ms.StackSys)
+	w.WriteGaugeUint64(NewMetricName("go_memstats_sys_bytes"), ms.Sys)
 }
 
-func (c *goMemstatsCollector) collectGCStats(ms *runtime.MemStats, w ExpfmtWriter) {
+func (c *goMemstatsCollector) collectGCStats(ms *runtime.MemStats, w MetricWriter) {
 	var pauses []uint64
 	if n := slices.Index(ms.PauseNs[:], 0); n == -1 {
 		// the entire ring buffer is full
@@ -70,20 +68,23 @@ func (c *goMemstatsCollector) collectGCStats(ms *runtime.MemStats, w ExpfmtWrite
 	}
 	slices.Sort(pauses)
 
-	goGCDurationSeconds := MustIdent("go_gc_duration_seconds")
-	const nq = len(quantileTags) - 1
-
+	const nq = len(gcQuantiles) - 1
+	var quantiles [len(gcQuantiles)]Quantile
 	for i := range nq {
-		w.WriteMetricDuration(MetricName{
-			Family: goGCDurationSeconds,
-			Tags:   []Tag{quantileTags[i]},
-		}, time.Duration(pauses[len(pauses)*i/nq]))
+		quantiles[i] = Quantile{
+			Quantile: gcQuantiles[i],
+			Value:    time.Duration(pauses[len(pauses)*i/nq]).Seconds(),
+		}
+	}
+	quantiles[nq] = Quantile{
+		Quantile: gcQuantiles[nq],
+		Value:    time.Duration(pauses[len(pauses)-1]).Seconds(),
 	}
-	w.WriteMetricDuration(MetricName{
-		Family: goGCDurationSeconds,
-		Tags:   []Tag{quantileTags[nq]},
-	}, time.Duration(pauses[len(pauses)-1]))
 
-	w.WriteLazyMetricUint64("go_gc_duration_seconds_count", uint64(ms.NumGC))
-	w.WriteLazyMetricDuration("go_gc_duration_seconds_sum", time.Duration(ms.PauseTotalNs))
+	w.WriteSummary(
+		NewMetricName("go_gc_duration_seconds"),
+		quantiles[:],
+		uint64(ms.NumGC),
+		time.Duration(ms.PauseTotalNs).Seconds(),
+	)
 }
@@ -8,20 +8,24 @@ package metrics
 // [NewGoCPUCollector], and [NewGoMemstatsCollector].
 func NewGoMetricsCollector() Collector {
 	return &goMetricsCollector{
-		info:     NewGoInfoCollector(),
-		gc:       NewGoGCCollector(),
-		memstats: NewGoMemstatsCollector(),
+		info:     NewGoInfoCollector().(MetricCollector),
+		gc:       NewGoGCCollector().(MetricCollector),
+		memstats: NewGoMemstatsCollector().(MetricCollector),
 	}
 }
 
 type goMetricsCollector struct {
-	info     Collector
-	gc       Collector
-	memstats Collector
+	info     MetricCollector
+	gc       MetricCollector
+	memstats MetricCollector
 }
 
 func (c *goMetricsCollector) Collect(w ExpfmtWriter) {
-	c.info.Collect(w)
-	c.gc.Collect(w)
-	c.memstats.Collect(w)
+	c.CollectMetrics(w)
+}
+
+func (c *goMetricsCollector) CollectMetrics(w MetricWriter) {
+	c.info.CollectMetrics(w)
+	c.gc.CollectMetrics(w)
+	c.memstats.CollectMetrics(w)
 }
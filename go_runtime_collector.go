@@ -6,7 +6,6 @@ import (
 	"regexp"
 	"runtime"
 	runtimemetrics "runtime/metrics"
-	"strconv"
 	"strings"
 )
 
@@ -14,6 +13,8 @@ import (
 type runtimeMetricName struct {
 	sample string
 	name   MetricName
+	// cumulative samples are written as counters
+	cumulative bool
 }
 
 var runtimeMetricReplacer = strings.NewReplacer("/", "_", ":", "_", "-", "_")
@@ -32,129 +33,57 @@ func makeRuntimeMetricNames(prefix string, filter *regexp.Regexp) []runtimeMetri
 			name: MetricName{
 				Family: MustIdent("go" + runtimeMetricReplacer.Replace(d.Name)),
 			},
+			cumulative: d.Cumulative,
 		})
 	}
 	return names
 }
 
-func collectRuntimeMetrics(w ExpfmtWriter, names []runtimeMetricName) {
+func collectRuntimeMetrics(w MetricWriter, names []runtimeMetricName) {
 	samples := make([]runtimemetrics.Sample, len(names))
 	for i, rm := range names {
 		samples[i].Name = rm.sample
 	}
 	runtimemetrics.Read(samples)
 	for i, rm := range names {
-		writeRuntimeMetric(w, rm.name, &samples[i])
+		writeRuntimeMetric(w, rm, &samples[i])
 	}
 }
 
-func writeRuntimeMetric(w ExpfmtWriter, name MetricName, sample *runtimemetrics.Sample) {
+func writeRuntimeMetric(w MetricWriter, rm runtimeMetricName, sample *runtimemetrics.Sample) {
 	kind := sample.Value.Kind()
 	switch kind {
 	case runtimemetrics.KindBad:
 		panic(fmt.Sprintf("metrics: unexpected runtimemetrics.KindBad for sample.Name=%q", sample.Name))
 	case runtimemetrics.KindUint64:
-		w.WriteMetricUint64(name, sample.Value.Uint64())
+		if rm.cumulative {
+			w.WriteCounterUint64(rm.name, sample.Value.Uint64())
+		} else {
+			w.WriteGaugeUint64(rm.name, sample.Value.Uint64())
+		}
 	case runtimemetrics.KindFloat64:
-		w.WriteMetricFloat64(name, sample.Value.Float64())
+		if rm.cumulative {
+			w.WriteCounterFloat64(rm.name, sample.Value.Float64())
+		} else {
+			w.WriteGaugeFloat64(rm.name, sample.Value.Float64())
+		}
 	case runtimemetrics.KindFloat64Histogram:
-		writeRuntimeHistogramMetric(w, name, sample.Value.Float64Histogram())
+		writeRuntimeHistogramMetric(w, rm.name, sample.Value.Float64Histogram())
 	default:
 		panic(fmt.Sprintf("metrics: unexpected metric kind=%d", kind))
 	}
 }
 
-func writeRuntimeHistogramMetric(w ExpfmtWriter, name MetricName, h *runtimemetrics.Float64Histogram) {
-	buckets := h.Buckets
-	counts := h.Counts
-	family := name.Family.String()
-	b := w.b
-
-	tagsSize := sizeOfTags(name.Tags, w.constantTags) + 1
-
-	const (
-		chunkVMRange = `_bucket{vmrange="`
-		chunkSum     = "_sum"
-		chunkCount   = "_count"
-	)
-
-	var totalCount uint64
+func writeRuntimeHistogramMetric(w MetricWriter, name MetricName, h *runtimemetrics.Float64Histogram) {
 	var sum float64
-	var nonZero int
-
-	for i, count := range counts {
-		totalCount += count
-		if count > 0 {
-			nonZero++
-			// Estimate sum using lower bound of each bucket (underestimate,
-			// same approach as prometheus/client_golang).
-			if !math.IsInf(buckets[i], -1) {
-				sum += buckets[i] * float64(count)
-			}
+	for i, count := range h.Counts {
+		// Estimate sum using lower bound of each bucket (underestimate,
+		// same approach as prometheus/client_golang).
+		if count > 0 && !math.IsInf(h.Buckets[i], -1) {
+			sum += h.Buckets[i] * float64(count)
 		}
 	}
-
-	if totalCount == 0 {
-		return
-	}
-
-	b.Grow(
-		(len(family)+len(chunkVMRange)+tagsSize+40)*nonZero +
-			len(family) + len(chunkSum) + tagsSize + 20 +
-			len(family) + len(chunkCount) + tagsSize + 20 +
-			64,
-	)
-
-	for i, count := range counts {
-		if count == 0 {
-			continue
-		}
-
-		lower := strconv.FormatFloat(buckets[i], 'g', -1, 64)
-		upper := strconv.FormatFloat(buckets[i+1], 'g', -1, 64)
-
-		b.WriteString(family)
-		b.WriteString(chunkVMRange)
-		b.WriteString(lower)
-		b.WriteString("...")
-		b.WriteString(upper)
-		b.WriteByte('"')
-		if len(w.constantTags) > 0 {
-			b.WriteByte(',')
-			b.WriteString(w.constantTags)
-		}
-		for _, tag := range name.Tags {
-			b.WriteByte(',')
-			writeTag(b, tag)
-		}
-		b.WriteString(`} `)
-		writeUint64(b, count)
-		b.WriteByte('\n')
-	}
-
-	// _sum
-	b.WriteString(family)
-	b.WriteString(chunkSum)
-	if tagsSize > 0 {
-		b.WriteByte('{')
-		writeTags(b, w.constantTags, name.Tags)
-		b.WriteByte('}')
-	}
-	b.WriteByte(' ')
-	writeFloat64(b, sum)
-	b.WriteByte('\n')
-
-	// _count
-	b.WriteString(family)
-	b.WriteString(chunkCount)
-	if tagsSize > 0 {
-		b.WriteByte('{')
-		writeTags(b, w.constantTags, name.Tags)
-		b.WriteByte('}')
-	}
-	b.WriteByte(' ')
-	writeUint64(b, totalCount)
-	b.WriteByte('\n')
+	w.WriteHistogram(name, h.Buckets, h.Counts, sum)
 }
 
 // GoInfoCollector emits go_info, go_info_ext, go_goroutines, and go_threads.
@@ -167,17 +96,21 @@ func NewGoInfoCollector() Collector {
 }
 
 func (c *goInfoCollector) Collect(w ExpfmtWriter) {
-	w.WriteLazyMetricUint64("go_info", 1,
+	c.CollectMetrics(w)
+}
+
+func (c *goInfoCollector) CollectMetrics(w MetricWriter) {
+	w.WriteGaugeUint64(NewMetricName("go_info",
 		"version", runtime.Version(),
-	)
-	w.WriteLazyMetricUint64("go_info_ext", 1,
+	), 1)
+	w.WriteGaugeUint64(NewMetricName("go_info_ext",
 		"compiler", runtime.Compiler,
 		"GOARCH", runtime.GOARCH,
 		"GOOS", runtime.GOOS,
-	)
-	w.WriteLazyMetricFloat64("go_goroutines", float64(runtime.NumGoroutine()))
+	), 1)
+	w.WriteGaugeFloat64(NewMetricName("go_goroutines"), float64(runtime.NumGoroutine()))
 	numThreads, _ := runtime.ThreadCreateProfile(nil)
-	w.WriteLazyMetricFloat64("go_threads", float64(numThreads))
+	w.WriteGaugeFloat64(NewMetricName("go_threads"), float64(numThreads))
 }
 
 // GoGCCollectorOption configures a [GoGCCollector].
@@ -205,6 +138,10 @@ func NewGoGCCollector(opts ...GoGCCollectorOption) Collector {
 }
 
 func (c *goGCCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *goGCCollector) CollectMetrics(w MetricWriter) {
 	collectRuntimeMetrics(w, c.runtimeMetrics)
 }
 
@@ -233,6 +170,10 @@ func NewGoMemoryCollector(opts ...GoMemoryCollectorOption) Collector {
 }
 
 func (c *goMemoryCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *goMemoryCollector) CollectMetrics(w MetricWriter) {
 	collectRuntimeMetrics(w, c.runtimeMetrics)
 }
 
@@ -261,6 +202,10 @@ func NewGoSchedCollector(opts ...GoSchedCollectorOption) Collector {
 }
 
 func (c *goSchedCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *goSchedCollector) CollectMetrics(w MetricWriter) {
 	collectRuntimeMetrics(w, c.runtimeMetrics)
 }
 
@@ -289,6 +234,10 @@ func NewGoCPUCollector(opts ...GoCPUCollectorOption) Collector {
 }
 
 func (c *goCPUCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *goCPUCollector) CollectMetrics(w MetricWriter) {
 	collectRuntimeMetrics(w, c.runtimeMetrics)
 }
 
@@ -317,6 +266,10 @@ func NewGoCgoCollector(opts ...GoCgoCollectorOption) Collector {
 }
 
 func (c *goCgoCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *goCgoCollector) CollectMetrics(w MetricWriter) {
 	collectRuntimeMetrics(w, c.runtimeMetrics)
 }
 
@@ -345,6 +298,10 @@ func NewGoSyncCollector(opts ...GoSyncCollectorOption) Collector {
 }
 
 func (c *goSyncCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *goSyncCollector) CollectMetrics(w MetricWriter) {
 	collectRuntimeMetrics(w, c.runtimeMetrics)
 }
 
@@ -373,5 +330,9 @@ func NewGoGodebugCollector(opts ...GoGodebugCollectorOption) Collector {
 }
 
 func (c *goGodebugCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *goGodebugCollector) CollectMetrics(w MetricWriter) {
 	collectRuntimeMetrics(w, c.runtimeMetrics)
 }
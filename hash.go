@@ -1,6 +1,9 @@
 package metrics
 
-import "hash/maphash"
+import (
+	"hash/maphash"
+	"sync/atomic"
+)
 
 // Metrics must to be uniqued by their family name and optional
 // tags. The hash we generate is done so that we don't trigger
@@ -112,3 +115,15 @@ func hashFinish(h *maphash.Hash, values ...string) metricHash {
 func hashString(s string) metricHash {
 	return metricHash(maphash.String(globalSeed, s))
 }
+
+// hashCollisions counts series that were stored under a probed hash because
+// their hash collided with a different series.
+var hashCollisions atomic.Uint64
+
+// probeHash returns the i-th key to try for a series with hash h. The first
+// key is h itself, and each later key is only used when all the previous
+// keys are taken by different series.
+func probeHash(h metricHash, i int) metricHash {
+	// the 64 bit golden ratio spreads the probes across the key space
+	return h + metricHash(i)*0x9e3779b97f4a7c15
+}
@@ -56,3 +56,73 @@ func TestHashPartial(t *testing.T) {
 		}),
 	)
 }
+
+func TestHashCollision(t *testing.T) {
+	set := NewSet()
+	vec := set.NewUint64Vec("requests_total", "code")
+	before := hashCollisions.Load()
+
+	// take the hash of code="200" with a different series
+	hash := getHashTags("requests_total", MustTags("code", "200"))
+	other := &Uint64{}
+	nm, loaded := set.storeNamedMetric(hash, &namedMetric{
+		name:   NewMetricName("other_total"),
+		metric: other,
+	})
+	assert.False(t, loaded)
+	assert.Equal(t, hash, nm.id)
+
+	c := vec.WithLabelValues("200")
+	assert.True(t, c != other)
+	assert.Equal(t, before+1, hashCollisions.Load())
+	// the fast path finds the series past the collision
+	assert.True(t, c == vec.WithLabelValues("200"))
+	assert.Equal(t, before+1, hashCollisions.Load())
+
+	c.Add(2)
+	other.Inc()
+	assertMarshal(t, set, []string{
+		"other_total 1",
+		`requests_total{code="200"} 2`,
+	})
+
+	// storing the same name at a collided hash loads the original
+	nm, loaded = set.storeNamedMetric(hash, &namedMetric{
+		name:   NewMetricName("requests_total", "code", "200"),
+		metric: &Uint64{},
+	})
+	assert.True(t, loaded)
+	assert.True(t, nm.metric == c)
+}
+
+func TestSetHashCollision(t *testing.T) {
+	set := NewSet()
+	sv := set.NewSetVec("shard")
+	before := hashCollisions.Load()
+
+	// take the hash of shard="a" with a different Set
+	hash := hashFinish(sv.partialHash, "a")
+	other := newSet()
+	other.idTags = MustTags("other", "b")
+	other.joinConstantTags(nil, other.idTags...)
+	stored, loaded := set.storeSet(hash, other)
+	assert.False(t, loaded)
+	assert.True(t, stored == other)
+
+	a := sv.WithLabelValue("a")
+	assert.True(t, a != other)
+	assert.Equal(t, before+1, hashCollisions.Load())
+	assert.True(t, a == sv.WithLabelValue("a"))
+
+	a.NewCounter("foo").Inc()
+	other.NewCounter("foo").Add(2)
+	assertMarshalUnordered(t, set, []string{
+		`foo{other="b"} 2`,
+		`foo{shard="a"} 1`,
+	})
+
+	// removing the value past the collision keeps the other Set
+	sv.RemoveByLabelValue("a")
+	assertMarshal(t, set, []string{`foo{other="b"} 2`})
+	assert.True(t, sv.WithLabelValue("a") != a)
+}
@@ -31,6 +31,9 @@ const (
 var (
 	bucketMultiplier = math.Pow(10, 1.0/bucketsPerDecimal)
 	bucketRanges     [totalBuckets]string
+	// bucketBounds are the bounds of each bucket as written in bucketRanges,
+	// bucketRanges[i] is from bucketBounds[i] to bucketBounds[i+1]
+	bucketBounds [totalBuckets + 1]float64
 )
 
 type (
@@ -124,14 +127,22 @@ func (h *Histogram) Update(val float64) {
 		return
 	}
 
+	if h.addToBucket(val, 1) {
+		h.sum.Add(val)
+	}
+}
+
+// addToBucket adds n to the bucket val falls into. It returns false if val
+// went into the lower bucket, and shouldn't be accounted for in the sum.
+func (h *Histogram) addToBucket(val float64, n uint64) bool {
 	bucketIdx := (math.Log10(val) - e10Min) * bucketsPerDecimal
 
 	switch {
 	case bucketIdx < 0:
-		h.lower.Add(1)
-		return
+		h.lower.Add(n)
+		return false
 	case bucketIdx >= histBuckets:
-		h.upper.Add(1)
+		h.upper.Add(n)
 	default:
 		idx := uint(bucketIdx)
 		if bucketIdx == float64(idx) && idx > 0 {
@@ -152,10 +163,9 @@ func (h *Histogram) Update(val float64) {
 				db = h.buckets[decimalBucketIdx].Load()
 			}
 		}
-		db[offset].Add(1)
+		db[offset].Add(n)
 	}
-
-	h.sum.Add(val)
+	return true
 }
 
 // Observe updates h with val, identical to [Histogram.Update].
@@ -345,6 +355,10 @@ func init() {
 	}
 
 	bucketRanges[totalBuckets-1] = formatBucket(math.Pow10(e10Max)) + "...+Inf"
+
+	for i, vmrange := range bucketRanges {
+		bucketBounds[i], bucketBounds[i+1], _ = parseVMRange(vmrange)
+	}
 }
 
 func formatBucket(v float64) string {
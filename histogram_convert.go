@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"math"
+	"slices"
+)
+
+// NewFixedHistogramView creates a new FixedHistogram view of a Histogram on
+// the global Set.
+// See [Set.NewFixedHistogramView].
+func NewFixedHistogramView(family string, src *Histogram, buckets []float64, tags ...string) {
+	defaultSet.NewFixedHistogramView(family, src, buckets, tags...)
+}
+
+// NewFixedHistogramView registers a read-only Prometheus-like histogram in s
+// with the given name, which is computed from src with [Histogram.ToFixed]
+// every time it is written.
+//
+// This allows emitting both `vmrange` and `le` style histograms from the same
+// observations while only paying for a single [Histogram.Update].
+//
+// family must be a Prometheus compatible identifier format, and must differ
+// from the family src was registered with, if any.
+//
+// Optional tags must be specified in [label, value] pairs, for instance,
+//
+//	NewFixedHistogramView("family", h, []float64{0.1, 0.5, 1}, "label1", "value1")
+//
+// This will panic if values are invalid or already registered.
+func (s *Set) NewFixedHistogramView(family string, src *Histogram, buckets []float64, tags ...string) {
+	buckets = getBuckets(buckets)
+	s.mustStoreMetric(&fixedHistogramView{
+		src:     src,
+		buckets: buckets,
+		labels:  labelsForBuckets(buckets),
+	}, MetricName{
+		Family: MustIdent(family),
+		Tags:   MustTags(tags...),
+	})
+}
+
+type fixedHistogramView struct {
+	src     *Histogram
+	buckets []float64
+	labels  []string
+}
+
+func (v *fixedHistogramView) marshalTo(w ExpfmtWriter, name MetricName) {
+	// avoid allocating for reasonably sized bucket layouts
+	var stack [32]uint64
+	var counts []uint64
+	if len(v.buckets) > len(stack) {
+		counts = make([]uint64, len(v.buckets))
+	} else {
+		counts = stack[:len(v.buckets)]
+	}
+
+	total := v.src.fixedCounts(v.buckets, counts)
+	writeFixedHistogram(
+		w, name, v.labels,
+		func(i int) uint64 { return counts[i] },
+		total, v.src.sum.Load(), total,
+	)
+}
+
+// ToFixed re-buckets h into the given `le` bucket layout as used by a
+// [FixedHistogram]. If buckets is empty, [DefBuckets] is used.
+//
+// Each `vmrange` bucket is counted in the smallest bucket whose bound is
+// greater than or equal to the end of the range, so an observation is never
+// counted in a bucket whose bound is below its value. When a bound falls
+// inside a `vmrange` bucket, that bucket's observations are counted in the
+// next bound up instead, which underestimates the count for that bound by at
+// most the observations within a factor of 10^(1/18) (about 13.6%) below it.
+// The count and sum are exact.
+func (h *Histogram) ToFixed(buckets []float64) FixedHistogramSnapshot {
+	buckets = getBuckets(buckets)
+	counts := make([]uint64, len(buckets))
+	total := h.fixedCounts(buckets, counts)
+	return FixedHistogramSnapshot{
+		Buckets: buckets,
+		Counts:  counts,
+		Inf:     total,
+		Count:   total,
+		Sum:     h.sum.Load(),
+	}
+}
+
+// fixedCounts fills counts with the cumulative counts of h for each of
+// the sorted bucket bounds, and returns the total count.
+func (h *Histogram) fixedCounts(buckets []float64, counts []uint64) (total uint64) {
+	clear(counts)
+
+	place := func(end float64, n uint64) {
+		total += n
+		if i, _ := slices.BinarySearch(buckets, end); i < len(buckets) {
+			counts[i] += n
+		}
+	}
+
+	if n := h.lower.Load(); n > 0 {
+		place(math.Pow10(e10Min), n)
+	}
+	for idx := range h.buckets {
+		if db := h.buckets[idx].Load(); db != nil {
+			for offset := range db {
+				if n := db[offset].Load(); n > 0 {
+					place(bucketEnd(idx*bucketsPerDecimal+offset), n)
+				}
+			}
+		}
+	}
+	if n := h.upper.Load(); n > 0 {
+		place(math.Inf(1), n)
+	}
+
+	for i := 1; i < len(counts); i++ {
+		counts[i] += counts[i-1]
+	}
+	return total
+}
+
+// ToHistogram approximates s as a [Histogram] with `vmrange` buckets.
+//
+// The values within each `le` bucket are unknown, so all of them are
+// attributed to the `vmrange` bucket containing the bucket's bound. This
+// overestimates each value by up to the width of the `le` bucket it was
+// observed in, plus up to a factor of 10^(1/18) (about 13.6%) for the width
+// of the `vmrange` bucket. Observations above the largest bound are
+// attributed to the `vmrange` bucket following it, and observations in
+// buckets with non-positive bounds go into the lowest `vmrange` bucket.
+// The count and sum are exact, except negative sums are dropped since
+// [Histogram] does not support negative values.
+//
+// A snapshot taken during concurrent updates may have a cumulative count
+// lower than the one of a previous bucket, which is then treated as an empty
+// bucket.
+func (s FixedHistogramSnapshot) ToHistogram() *Histogram {
+	h := &Histogram{}
+
+	var prev uint64
+	for i, bound := range s.Buckets {
+		if s.Counts[i] <= prev {
+			continue
+		}
+		n := s.Counts[i] - prev
+		prev = s.Counts[i]
+		if bound <= 0 {
+			h.lower.Add(n)
+		} else {
+			h.addToBucket(bound, n)
+		}
+	}
+
+	if s.Inf > prev {
+		n := s.Inf - prev
+		if last := len(s.Buckets) - 1; last >= 0 && s.Buckets[last] > 0 {
+			h.addToBucket(s.Buckets[last]*bucketMultiplier, n)
+		} else {
+			h.upper.Add(n)
+		}
+	}
+
+	h.sum.Add(s.Sum)
+	return h
+}
+
+// bucketEnd returns the upper end of the range for the histogram
+// bucket at idx.
+func bucketEnd(idx int) float64 {
+	if (idx+1)%bucketsPerDecimal == 0 {
+		// exact powers of 10 so they compare equal to bucket bounds
+		return math.Pow10(e10Min + (idx+1)/bucketsPerDecimal)
+	}
+	return math.Pow(10, e10Min+float64(idx+1)/bucketsPerDecimal)
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestHistogramToFixed(t *testing.T) {
+	var h Histogram
+	h.Update(0)
+	h.Update(0.5)
+	h.Update(1)
+	h.Update(1.5)
+	h.Update(10)
+	h.Update(1000)
+	h.Update(math.Inf(1))
+
+	s := h.ToFixed([]float64{10, 1, 100})
+	assert.SlicesEqual(t, s.Buckets, []float64{1, 10, 100})
+	assert.SlicesEqual(t, s.Counts, []uint64{3, 5, 5})
+	assert.Equal(t, s.Inf, 7)
+	assert.Equal(t, s.Count, 7)
+	assert.Equal(t, s.Sum, h.sum.Load())
+
+	// a bound inside of a vmrange bucket is underestimated
+	var h2 Histogram
+	h2.Update(0.49)
+	s = h2.ToFixed([]float64{0.5, 1})
+	assert.SlicesEqual(t, s.Counts, []uint64{0, 1})
+}
+
+func TestFixedHistogramSnapshotToHistogram(t *testing.T) {
+	f := newFixedHistogram([]float64{0, 1, 10})
+	f.Update(-1)
+	f.Update(0.5)
+	f.Update(5)
+	f.Update(5)
+	f.Update(50)
+
+	set := NewSet()
+	h := f.Snapshot().ToHistogram()
+	set.mustStoreMetric(h, NewMetricName("hist"))
+	assertMarshal(t, set, []string{
+		`hist_bucket{vmrange="0...1.000e-09"} 1`,
+		`hist_bucket{vmrange="8.799e-01...1.000e+00"} 1`,
+		`hist_bucket{vmrange="8.799e+00...1.000e+01"} 2`,
+		`hist_bucket{vmrange="1.000e+01...1.136e+01"} 1`,
+		`hist_sum 59.5`,
+		`hist_count 5`,
+	})
+}
+
+func TestFixedHistogramSnapshotToHistogramNonMonotonic(t *testing.T) {
+	// as seen by a Snapshot racing an Update of 5, which was counted in the
+	// `le="10"` bucket but not yet in `le="100"` and `+Inf`
+	s := FixedHistogramSnapshot{
+		Buckets: []float64{1, 10, 100},
+		Counts:  []uint64{2, 4, 3},
+		Inf:     3,
+		Sum:     10,
+	}
+	set := NewSet()
+	set.mustStoreMetric(s.ToHistogram(), NewMetricName("hist"))
+	assertMarshal(t, set, []string{
+		`hist_bucket{vmrange="8.799e-01...1.000e+00"} 2`,
+		`hist_bucket{vmrange="8.799e+00...1.000e+01"} 2`,
+		`hist_sum 10`,
+		`hist_count 4`,
+	})
+}
+
+func TestFixedHistogramView(t *testing.T) {
+	set := NewSet()
+	h := set.NewHistogram("hist")
+	set.NewFixedHistogramView("hist_fixed", h, []float64{1, 10}, "a", "b")
+
+	assertMarshal(t, set, []string{
+		`hist_fixed_bucket{le="1",a="b"} 0`,
+		`hist_fixed_bucket{le="10",a="b"} 0`,
+		`hist_fixed_bucket{le="+Inf",a="b"} 0`,
+		`hist_fixed_sum{a="b"} 0`,
+		`hist_fixed_count{a="b"} 0`,
+	})
+
+	h.Update(1)
+	h.Update(5)
+	h.Update(50)
+
+	assertMarshal(t, set, []string{
+		`hist_bucket{vmrange="8.799e-01...1.000e+00"} 1`,
+		`hist_bucket{vmrange="4.642e+00...5.275e+00"} 1`,
+		`hist_bucket{vmrange="4.642e+01...5.275e+01"} 1`,
+		`hist_sum 56`,
+		`hist_count 3`,
+		`hist_fixed_bucket{le="1",a="b"} 1`,
+		`hist_fixed_bucket{le="10",a="b"} 2`,
+		`hist_fixed_bucket{le="+Inf",a="b"} 3`,
+		`hist_fixed_sum{a="b"} 56`,
+		`hist_fixed_count{a="b"} 3`,
+	})
+}
@@ -30,6 +30,22 @@ func ExampleHistogramVec() {
 	}
 }
 
+func ExampleNewFixedHistogramView() {
+	h := metrics.NewHistogram("request_duration_seconds")
+
+	// Also expose h with Prometheus-like `le` buckets without
+	// having to update two histograms.
+	metrics.NewFixedHistogramView(
+		"request_duration_seconds_le",
+		h,
+		[]float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1},
+	)
+
+	startTime := time.Now()
+	processRequest()
+	h.UpdateDuration(startTime)
+}
+
 func processRequest() string {
 	return "foobar"
 }
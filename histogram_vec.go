@@ -29,7 +29,7 @@ func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
 func (h *HistogramVec) withLabelValues(set *Set, values []string) *Histogram {
 	hash := hashFinish(h.partialHash, values...)
 
-	nm, ok := set.metrics.Load(hash)
+	nm, ok := set.loadMetricFromVec(hash, h.family, h.partialTags, values)
 	if !ok {
 		nm = set.loadOrStoreMetricFromVec(
 			&Histogram{}, hash, h.family, h.partialTags, values,
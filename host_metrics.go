@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"io/fs"
+	"os"
+	"regexp"
+)
+
+// HostCollectorOption configures a [NewHostCollector].
+type HostCollectorOption func(*hostCollector)
+
+// WithHostProcFS reads host files from fsys instead of /proc. fsys is
+// expected to be the root of a procfs, containing loadavg, meminfo, stat,
+// net/dev and diskstats, and is useful for testing or when the host's procfs
+// is mounted elsewhere, such as /host/proc.
+func WithHostProcFS(fsys fs.FS) HostCollectorOption {
+	return func(c *hostCollector) { c.fsys = fsys }
+}
+
+// WithHostDiskFilter sets a regexp for which devices in /proc/diskstats to
+// collect. By default, partitions, loop and ram devices are skipped.
+func WithHostDiskFilter(re *regexp.Regexp) HostCollectorOption {
+	return func(c *hostCollector) { c.diskFilter = re }
+}
+
+// defaultHostDiskIgnore matches the devices skipped by default, following
+// node_exporter.
+var defaultHostDiskIgnore = regexp.MustCompile(`^(z?ram|loop|fd|(h|s|v|xv)d[a-z]|nvme\d+n\d+p)\d+$`)
+
+type hostCollector struct {
+	fsys       fs.FS
+	diskFilter *regexp.Regexp
+}
+
+// NewHostCollector is a [MetricCollector] that yields a subset of host level metrics,
+// following the names used by node_exporter, for environments which can't
+// run it:
+//
+//   - node_load1, node_load5, node_load15 from loadavg
+//   - node_memory_*_bytes from meminfo
+//   - node_cpu_seconds_total{cpu, mode} and node_context_switches_total,
+//     node_boot_time_seconds, node_forks_total, node_procs_running,
+//     node_procs_blocked from stat
+//   - node_network_{receive,transmit}_{bytes,packets,errs,drop}_total{device}
+//     from net/dev
+//   - node_disk_* {device} from diskstats
+//
+// This is not registered by default. This is only supported on Linux, and
+// yields nothing elsewhere.
+func NewHostCollector(opts ...HostCollectorOption) Collector {
+	c := &hostCollector{
+		fsys: os.DirFS("/proc"),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *hostCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
@@ -0,0 +1,279 @@
+//go:build linux
+
+package metrics
+
+import (
+	"bytes"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// userHZ is the unit of times in /proc/stat, which is fixed at 100 on all
+// architectures Go supports.
+const userHZ = 100
+
+var (
+	hostCPULabel    = MustLabel("cpu")
+	hostModeLabel   = MustLabel("mode")
+	hostDeviceLabel = MustLabel("device")
+)
+
+// hostColumn is a metric family read from a column of a per device file.
+type hostColumn struct {
+	family string
+	field  int
+	// scale converts the value to the unit of the metric, or 1
+	scale float64
+}
+
+// hostRow is the fields of a per device file for a device.
+type hostRow struct {
+	device string
+	fields []uint64
+}
+
+var hostNetDevColumns = []hostColumn{
+	{"node_network_receive_bytes_total", 0, 1},
+	{"node_network_receive_packets_total", 1, 1},
+	{"node_network_receive_errs_total", 2, 1},
+	{"node_network_receive_drop_total", 3, 1},
+	{"node_network_transmit_bytes_total", 8, 1},
+	{"node_network_transmit_packets_total", 9, 1},
+	{"node_network_transmit_errs_total", 10, 1},
+	{"node_network_transmit_drop_total", 11, 1},
+}
+
+// diskstats sectors are always 512 bytes, and times are in milliseconds.
+//
+// See https://docs.kernel.org/admin-guide/iostats.html
+var hostDiskColumns = []hostColumn{
+	{"node_disk_reads_completed_total", 0, 1},
+	{"node_disk_reads_merged_total", 1, 1},
+	{"node_disk_read_bytes_total", 2, 512},
+	{"node_disk_read_time_seconds_total", 3, 0.001},
+	{"node_disk_writes_completed_total", 4, 1},
+	{"node_disk_writes_merged_total", 5, 1},
+	{"node_disk_written_bytes_total", 6, 512},
+	{"node_disk_write_time_seconds_total", 7, 0.001},
+	{"node_disk_io_now", 8, 1},
+	{"node_disk_io_time_seconds_total", 9, 0.001},
+	{"node_disk_io_time_weighted_seconds_total", 10, 0.001},
+}
+
+var hostCPUModes = [...]string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+
+func (c *hostCollector) CollectMetrics(w MetricWriter) {
+	if data, err := fs.ReadFile(c.fsys, "loadavg"); err == nil {
+		writeLoadavg(w, data)
+	}
+	if data, err := fs.ReadFile(c.fsys, "meminfo"); err == nil {
+		writeMeminfo(w, data)
+	}
+	if data, err := fs.ReadFile(c.fsys, "stat"); err == nil {
+		writeStat(w, data)
+	}
+	if data, err := fs.ReadFile(c.fsys, "net/dev"); err == nil {
+		writeHostColumns(w, parseNetDev(data), hostNetDevColumns)
+	}
+	if data, err := fs.ReadFile(c.fsys, "diskstats"); err == nil {
+		writeHostColumns(w, c.parseDiskstats(data), hostDiskColumns)
+	}
+}
+
+func writeLoadavg(w MetricWriter, data []byte) {
+	fields := bytes.Fields(data)
+	if len(fields) < 3 {
+		return
+	}
+	for i, family := range [...]string{"node_load1", "node_load5", "node_load15"} {
+		if v, err := strconv.ParseFloat(string(fields[i]), 64); err == nil {
+			w.WriteGaugeFloat64(NewMetricName(family), v)
+		}
+	}
+}
+
+var meminfoReplacer = strings.NewReplacer("(", "_", ")", "")
+
+// writeMeminfo writes each field of meminfo as node_memory_<field>_bytes, or
+// node_memory_<field> for fields that are not sizes, such as HugePages_Total.
+func writeMeminfo(w MetricWriter, data []byte) {
+	for line := range bytes.Lines(data) {
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		fields := bytes.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(string(fields[0]), 10, 64)
+		if err != nil {
+			continue
+		}
+		family := "node_memory_" + meminfoReplacer.Replace(string(key))
+		if len(fields) == 2 && string(fields[1]) == "kB" {
+			family += "_bytes"
+			v *= 1024
+		}
+		if !validateIdent(family) {
+			continue
+		}
+		w.WriteGaugeUint64(NewMetricName(family), v)
+	}
+}
+
+func writeStat(w MetricWriter, data []byte) {
+	var cpus []hostRow
+	var ctxt, btime, forks, running, blocked uint64
+	var haveCtxt, haveBtime, haveForks, haveRunning, haveBlocked bool
+
+	for line := range bytes.Lines(data) {
+		fields := bytes.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		key := string(fields[0])
+		if cpu, ok := strings.CutPrefix(key, "cpu"); ok {
+			// skip the aggregate of all cpus
+			if cpu != "" {
+				cpus = append(cpus, hostRow{device: cpu, fields: parseUints(fields[1:])})
+			}
+			continue
+		}
+		v, err := strconv.ParseUint(string(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "ctxt":
+			ctxt, haveCtxt = v, true
+		case "btime":
+			btime, haveBtime = v, true
+		case "processes":
+			forks, haveForks = v, true
+		case "procs_running":
+			running, haveRunning = v, true
+		case "procs_blocked":
+			blocked, haveBlocked = v, true
+		}
+	}
+
+	if len(cpus) > 0 {
+		family := MustIdent("node_cpu_seconds_total")
+		for _, cpu := range cpus {
+			cpuTag := NewTag(hostCPULabel, SanitizeValue(cpu.device))
+			for i, mode := range hostCPUModes {
+				if i >= len(cpu.fields) {
+					break
+				}
+				w.WriteCounterFloat64(MetricName{
+					Family: family,
+					Tags:   []Tag{cpuTag, NewTag(hostModeLabel, UnsafeValue(mode))},
+				}, float64(cpu.fields[i])/userHZ)
+			}
+		}
+	}
+	if haveCtxt {
+		w.WriteCounterUint64(NewMetricName("node_context_switches_total"), ctxt)
+	}
+	if haveBtime {
+		w.WriteGaugeUint64(NewMetricName("node_boot_time_seconds"), btime)
+	}
+	if haveForks {
+		w.WriteCounterUint64(NewMetricName("node_forks_total"), forks)
+	}
+	if haveRunning {
+		w.WriteGaugeUint64(NewMetricName("node_procs_running"), running)
+	}
+	if haveBlocked {
+		w.WriteGaugeUint64(NewMetricName("node_procs_blocked"), blocked)
+	}
+}
+
+// parseNetDev parses /proc/net/dev, which has two header lines followed by
+// a line per interface:
+//
+//	eth0: 28720120 4042 0 0 0 0 0 0 28720120 4042 0 0 0 0 0 0
+func parseNetDev(data []byte) []hostRow {
+	var rows []hostRow
+	for line := range bytes.Lines(data) {
+		device, values, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		rows = append(rows, hostRow{
+			device: string(bytes.TrimSpace(device)),
+			fields: parseUints(bytes.Fields(values)),
+		})
+	}
+	return rows
+}
+
+// parseDiskstats parses /proc/diskstats, which has a line per device of
+// major, minor, device name, then the stats.
+func (c *hostCollector) parseDiskstats(data []byte) []hostRow {
+	var rows []hostRow
+	for line := range bytes.Lines(data) {
+		fields := bytes.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		device := string(fields[2])
+		if c.diskFilter != nil {
+			if !c.diskFilter.MatchString(device) {
+				continue
+			}
+		} else if defaultHostDiskIgnore.MatchString(device) {
+			continue
+		}
+		rows = append(rows, hostRow{device: device, fields: parseUints(fields[3:])})
+	}
+	return rows
+}
+
+// writeHostColumns writes each column as a family, with a metric for each
+// row tagged by its device.
+func writeHostColumns(w MetricWriter, rows []hostRow, columns []hostColumn) {
+	if len(rows) == 0 {
+		return
+	}
+	tags := make([][]Tag, len(rows))
+	for i, row := range rows {
+		tags[i] = []Tag{NewTag(hostDeviceLabel, SanitizeValue(row.device))}
+	}
+	for _, col := range columns {
+		family := MustIdent(col.family)
+		counter := strings.HasSuffix(col.family, "_total")
+		for i, row := range rows {
+			if col.field >= len(row.fields) {
+				continue
+			}
+			name := MetricName{Family: family, Tags: tags[i]}
+			switch {
+			case col.scale != 1 && counter:
+				w.WriteCounterFloat64(name, float64(row.fields[col.field])*col.scale)
+			case col.scale != 1:
+				w.WriteGaugeFloat64(name, float64(row.fields[col.field])*col.scale)
+			case counter:
+				w.WriteCounterUint64(name, row.fields[col.field])
+			default:
+				w.WriteGaugeUint64(name, row.fields[col.field])
+			}
+		}
+	}
+}
+
+// parseUints parses fields as unsigned integers, stopping at the first
+// field that isn't.
+func parseUints(fields [][]byte) []uint64 {
+	values := make([]uint64, 0, len(fields))
+	for _, f := range fields {
+		v, err := strconv.ParseUint(string(f), 10, 64)
+		if err != nil {
+			break
+		}
+		values = append(values, v)
+	}
+	return values
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestHostCollector(t *testing.T) {
+	set := NewSet()
+	set.RegisterCollector(NewHostCollector(WithHostProcFS(os.DirFS("testdata/proc"))))
+	assertMarshal(t, set, []string{
+		"node_load1 0.72",
+		"node_load5 0.61",
+		"node_load15 0.41",
+		"node_memory_MemTotal_bytes 2097152000",
+		"node_memory_MemFree_bytes 1048576000",
+		"node_memory_MemAvailable_bytes 1572864000",
+		"node_memory_Active_anon_bytes 1024000",
+		"node_memory_HugePages_Total 0",
+		"node_memory_Hugepagesize_bytes 2097152",
+		`node_cpu_seconds_total{cpu="0",mode="user"} 2`,
+		`node_cpu_seconds_total{cpu="0",mode="nice"} 0`,
+		`node_cpu_seconds_total{cpu="0",mode="system"} 0.5`,
+		`node_cpu_seconds_total{cpu="0",mode="idle"} 5`,
+		`node_cpu_seconds_total{cpu="0",mode="iowait"} 0.1`,
+		`node_cpu_seconds_total{cpu="0",mode="irq"} 0`,
+		`node_cpu_seconds_total{cpu="0",mode="softirq"} 0.03`,
+		`node_cpu_seconds_total{cpu="0",mode="steal"} 0.05`,
+		`node_cpu_seconds_total{cpu="1",mode="user"} 1`,
+		`node_cpu_seconds_total{cpu="1",mode="nice"} 0`,
+		`node_cpu_seconds_total{cpu="1",mode="system"} 0.5`,
+		`node_cpu_seconds_total{cpu="1",mode="idle"} 5`,
+		`node_cpu_seconds_total{cpu="1",mode="iowait"} 0`,
+		`node_cpu_seconds_total{cpu="1",mode="irq"} 0`,
+		`node_cpu_seconds_total{cpu="1",mode="softirq"} 0`,
+		`node_cpu_seconds_total{cpu="1",mode="steal"} 0`,
+		"node_context_switches_total 1169889",
+		"node_boot_time_seconds 1792385858",
+		"node_forks_total 15589",
+		"node_procs_running 2",
+		"node_procs_blocked 0",
+		`node_network_receive_bytes_total{device="lo"} 1000`,
+		`node_network_receive_bytes_total{device="eth0"} 500000`,
+		`node_network_receive_packets_total{device="lo"} 10`,
+		`node_network_receive_packets_total{device="eth0"} 400`,
+		`node_network_receive_errs_total{device="lo"} 0`,
+		`node_network_receive_errs_total{device="eth0"} 1`,
+		`node_network_receive_drop_total{device="lo"} 0`,
+		`node_network_receive_drop_total{device="eth0"} 2`,
+		`node_network_transmit_bytes_total{device="lo"} 1000`,
+		`node_network_transmit_bytes_total{device="eth0"} 250000`,
+		`node_network_transmit_packets_total{device="lo"} 10`,
+		`node_network_transmit_packets_total{device="eth0"} 300`,
+		`node_network_transmit_errs_total{device="lo"} 0`,
+		`node_network_transmit_errs_total{device="eth0"} 3`,
+		`node_network_transmit_drop_total{device="lo"} 0`,
+		`node_network_transmit_drop_total{device="eth0"} 4`,
+		`node_disk_reads_completed_total{device="nvme0n1"} 100`,
+		`node_disk_reads_merged_total{device="nvme0n1"} 5`,
+		`node_disk_read_bytes_total{device="nvme0n1"} 1024000`,
+		`node_disk_read_time_seconds_total{device="nvme0n1"} 0.04`,
+		`node_disk_writes_completed_total{device="nvme0n1"} 50`,
+		`node_disk_writes_merged_total{device="nvme0n1"} 6`,
+		`node_disk_written_bytes_total{device="nvme0n1"} 512000`,
+		`node_disk_write_time_seconds_total{device="nvme0n1"} 0.03`,
+		`node_disk_io_now{device="nvme0n1"} 1`,
+		`node_disk_io_time_seconds_total{device="nvme0n1"} 0.06`,
+		`node_disk_io_time_weighted_seconds_total{device="nvme0n1"} 0.07`,
+	})
+}
+
+func TestHostCollectorDiskFilter(t *testing.T) {
+	set := NewSet()
+	set.RegisterCollector(NewHostCollector(
+		WithHostProcFS(os.DirFS("testdata/proc")),
+		WithHostDiskFilter(regexp.MustCompile(`^loop`)),
+	))
+
+	var b bytes.Buffer
+	set.WritePrometheusUnthrottled(&b)
+	assert.True(t, strings.Contains(b.String(), `node_disk_reads_completed_total{device="loop0"} 1`))
+	assert.False(t, strings.Contains(b.String(), `nvme0n1`))
+}
+
+func TestHostCollectorMissing(t *testing.T) {
+	set := NewSet()
+	set.RegisterCollector(NewHostCollector(WithHostProcFS(os.DirFS("testdata/missing"))))
+	assertMarshal(t, set, nil)
+
+	set = NewSet()
+	set.RegisterCollector(NewHostCollector())
+	set.WritePrometheusUnthrottled(io.Discard)
+}
@@ -0,0 +1,5 @@
+//go:build !linux
+
+package metrics
+
+func (c *hostCollector) CollectMetrics(w MetricWriter) {}
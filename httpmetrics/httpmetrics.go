@@ -0,0 +1,133 @@
+/*
+Package httpmetrics provides instrumentation for net/http servers and clients.
+
+Requests are labelled by method, status class (such as "2xx") and route. The
+route is taken from the pattern matched by an [http.ServeMux], such as
+"GET /items/{id}", rather than the raw path, to avoid unbounded cardinality.
+
+For example:
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", getItem)
+	http.ListenAndServe(":8080", httpmetrics.Middleware(mux))
+
+Client requests are instrumented with a [NewTransport] wrapping an
+[http.RoundTripper], labelled by host instead of route.
+*/
+package httpmetrics
+
+import (
+	"net/http"
+
+	"go.withmatt.com/metrics"
+)
+
+// UnmatchedRoute is the route label value used for requests that did not
+// match any route, such as a 404 from an [http.ServeMux].
+const UnmatchedRoute = "unmatched"
+
+// Option configures instrumentation.
+type Option func(*config)
+
+type config struct {
+	durationBuckets []float64
+	sizeBuckets     []float64
+	route           func(r *http.Request) string
+}
+
+// WithDurationBuckets records durations into Prometheus-like histograms with
+// the given buckets instead of VictoriaMetrics-like histograms.
+// See [metrics.FixedHistogram].
+func WithDurationBuckets(buckets []float64) Option {
+	return func(c *config) { c.durationBuckets = buckets }
+}
+
+// WithSizeBuckets records request and response sizes into Prometheus-like
+// histograms with the given buckets instead of VictoriaMetrics-like
+// histograms.
+// See [metrics.FixedHistogram].
+func WithSizeBuckets(buckets []float64) Option {
+	return func(c *config) { c.sizeBuckets = buckets }
+}
+
+// WithRouteFunc sets a function to determine the route label of a request
+// once it has been handled, for routers other than [http.ServeMux].
+//
+// The returned route must have bounded cardinality.
+func WithRouteFunc(fn func(r *http.Request) string) Option {
+	return func(c *config) { c.route = fn }
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		route: patternRoute,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// patternRoute returns the pattern matched by an [http.ServeMux].
+func patternRoute(r *http.Request) string {
+	if r.Pattern == "" {
+		return UnmatchedRoute
+	}
+	return r.Pattern
+}
+
+// vecFactory creates metric Vecs, implemented by a [metrics.Set] and
+// globalSet.
+type vecFactory interface {
+	NewUint64Vec(family string, labels ...string) *metrics.Uint64Vec
+	NewInt64Vec(family string, labels ...string) *metrics.Int64Vec
+	NewHistogramVec(family string, labels ...string) *metrics.HistogramVec
+	NewFixedHistogramVec(family string, buckets []float64, labels ...string) *metrics.FixedHistogramVec
+}
+
+// globalSet creates metric Vecs on the global metrics Set.
+type globalSet struct{}
+
+func (globalSet) NewUint64Vec(family string, labels ...string) *metrics.Uint64Vec {
+	return metrics.NewUint64Vec(family, labels...)
+}
+
+func (globalSet) NewInt64Vec(family string, labels ...string) *metrics.Int64Vec {
+	return metrics.NewInt64Vec(family, labels...)
+}
+
+func (globalSet) NewHistogramVec(family string, labels ...string) *metrics.HistogramVec {
+	return metrics.NewHistogramVec(family, labels...)
+}
+
+func (globalSet) NewFixedHistogramVec(family string, buckets []float64, labels ...string) *metrics.FixedHistogramVec {
+	return metrics.NewFixedHistogramVec(family, buckets, labels...)
+}
+
+// observerVec returns the [metrics.Observer] for the label values.
+type observerVec func(values ...string) metrics.Observer
+
+// newObserverVec creates a VictoriaMetrics-like histogram Vec, or a
+// Prometheus-like histogram Vec if buckets are provided.
+func newObserverVec(f vecFactory, family string, buckets []float64, labels ...string) observerVec {
+	if buckets == nil {
+		vec := f.NewHistogramVec(family, labels...)
+		return func(values ...string) metrics.Observer {
+			return vec.WithLabelValues(values...)
+		}
+	}
+	vec := f.NewFixedHistogramVec(family, buckets, labels...)
+	return func(values ...string) metrics.Observer {
+		return vec.WithLabelValues(values...)
+	}
+}
+
+var statusClasses = [...]string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// statusClass returns the class of the status code, such as "2xx".
+func statusClass(code int) string {
+	if code < 100 || code >= 600 {
+		return "unknown"
+	}
+	return statusClasses[code/100-1]
+}
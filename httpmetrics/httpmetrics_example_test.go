@@ -0,0 +1,41 @@
+package httpmetrics_test
+
+import (
+	"net/http"
+
+	"go.withmatt.com/metrics"
+	"go.withmatt.com/metrics/httpmetrics"
+	"go.withmatt.com/metrics/promhttp"
+)
+
+func ExampleMiddleware() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	// Requests are labelled by the matched pattern, "GET /items/{id}".
+	http.ListenAndServe(":8080", httpmetrics.Middleware(mux))
+}
+
+func ExampleMiddlewareFor() {
+	set := metrics.NewSet()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	// Record durations into Prometheus-like `le` histograms.
+	handler := httpmetrics.MiddlewareFor(set, mux,
+		httpmetrics.WithDurationBuckets(metrics.DefBuckets),
+	)
+	http.ListenAndServe(":8080", handler)
+}
+
+func ExampleNewTransport() {
+	set := metrics.NewSet()
+
+	// A nil RoundTripper wraps http.DefaultTransport.
+	client := &http.Client{
+		Transport: httpmetrics.NewTransport(set, nil),
+	}
+	client.Get("https://example.com/")
+}
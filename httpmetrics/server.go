@@ -0,0 +1,111 @@
+package httpmetrics
+
+import (
+	"io"
+	"net/http"
+
+	"go.withmatt.com/metrics"
+)
+
+// Middleware instruments next, recording into the global metrics Set.
+// See [MiddlewareFor].
+func Middleware(next http.Handler, opts ...Option) http.Handler {
+	return newServer(globalSet{}, opts).wrap(next)
+}
+
+// MiddlewareFor instruments next, recording the following metrics into set:
+//
+//   - http_server_requests_total{method, code, route}
+//   - http_server_requests_in_flight{method}
+//   - http_server_request_duration_seconds{method, code, route}
+//   - http_server_request_size_bytes{method, code, route}
+//   - http_server_response_size_bytes{method, code, route}
+//
+// Where code is the status class, such as "2xx".
+//
+// The wrapped [http.ResponseWriter] retains support for [http.Flusher],
+// [http.Hijacker] and [io.ReaderFrom] if the original supports them, and
+// works with [http.ResponseController].
+func MiddlewareFor(set *metrics.Set, next http.Handler, opts ...Option) http.Handler {
+	return newServer(set, opts).wrap(next)
+}
+
+type server struct {
+	cfg *config
+
+	requests     *metrics.Uint64Vec
+	inFlight     *metrics.Int64Vec
+	duration     observerVec
+	requestSize  observerVec
+	responseSize observerVec
+}
+
+func newServer(f vecFactory, opts []Option) *server {
+	cfg := newConfig(opts)
+	return &server{
+		cfg:          cfg,
+		requests:     f.NewUint64Vec("http_server_requests_total", "method", "code", "route"),
+		inFlight:     f.NewInt64Vec("http_server_requests_in_flight", "method"),
+		duration:     newObserverVec(f, "http_server_request_duration_seconds", cfg.durationBuckets, "method", "code", "route"),
+		requestSize:  newObserverVec(f, "http_server_request_size_bytes", cfg.sizeBuckets, "method", "code", "route"),
+		responseSize: newObserverVec(f, "http_server_response_size_bytes", cfg.sizeBuckets, "method", "code", "route"),
+	}
+}
+
+func (s *server) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := metrics.NewTimer(nil)
+		method := normalizeMethod(r.Method)
+
+		inFlight := s.inFlight.WithLabelValues(method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		// count the body as it's read if the length isn't known up front
+		var body *countingReader
+		if r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody {
+			body = &countingReader{ReadCloser: r.Body}
+			r.Body = body
+		}
+
+		rw := &responseWriter{w: w}
+		next.ServeHTTP(wrapResponseWriter(rw), r)
+
+		code := statusClass(rw.status())
+		route := s.cfg.route(r)
+
+		requestSize := max(r.ContentLength, 0)
+		if body != nil {
+			requestSize = body.n
+		}
+
+		s.requests.WithLabelValues(method, code, route).Inc()
+		t.ObserveDurationTo(s.duration(method, code, route))
+		s.requestSize(method, code, route).Observe(float64(requestSize))
+		s.responseSize(method, code, route).Observe(float64(rw.written))
+	})
+}
+
+// normalizeMethod bounds the cardinality of the method label to the
+// standard methods.
+func normalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
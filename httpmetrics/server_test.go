@@ -0,0 +1,139 @@
+package httpmetrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics"
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	set := metrics.NewSet()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+	h := MiddlewareFor(set, mux,
+		WithDurationBuckets([]float64{60}),
+		WithSizeBuckets([]float64{10}),
+	)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/items/1", strings.NewReader("abc")))
+	assert.Equal(t, rec.Code, http.StatusCreated)
+
+	// unknown length bodies are counted as they're read
+	req := httptest.NewRequest("POST", "/items/2", io.MultiReader(strings.NewReader("abcdefghijkl")))
+	req.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/nope", nil))
+
+	lines := writeLines(set)
+	for _, want := range []string{
+		`http_server_requests_total{method="POST",code="2xx",route="POST /items/{id}"} 2`,
+		`http_server_requests_total{method="OTHER",code="4xx",route="unmatched"} 1`,
+		`http_server_requests_in_flight{method="POST"} 0`,
+		`http_server_request_duration_seconds_count{method="POST",code="2xx",route="POST /items/{id}"} 2`,
+		`http_server_request_size_bytes_bucket{le="10",method="POST",code="2xx",route="POST /items/{id}"} 1`,
+		`http_server_request_size_bytes_sum{method="POST",code="2xx",route="POST /items/{id}"} 15`,
+		`http_server_response_size_bytes_sum{method="POST",code="2xx",route="POST /items/{id}"} 10`,
+		`http_server_response_size_bytes_sum{method="OTHER",code="4xx",route="unmatched"} 19`,
+	} {
+		assert.True(t, slices.Contains(lines, want), assert.Sprintf("missing %s", want))
+	}
+}
+
+func TestMiddlewareRouteFunc(t *testing.T) {
+	set := metrics.NewSet()
+	h := MiddlewareFor(set, http.NotFoundHandler(), WithRouteFunc(func(r *http.Request) string {
+		return "custom"
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	lines := writeLines(set)
+	assert.True(t, slices.Contains(lines, `http_server_requests_total{method="GET",code="4xx",route="custom"} 1`))
+}
+
+func TestMiddlewareInterfaces(t *testing.T) {
+	set := metrics.NewSet()
+
+	type result struct {
+		flusher, hijacker, readerFrom bool
+	}
+	results := make(chan result, 1)
+	h := MiddlewareFor(set, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, isFlusher := w.(http.Flusher)
+		_, isHijacker := w.(http.Hijacker)
+		_, isReaderFrom := w.(io.ReaderFrom)
+		results <- result{isFlusher, isHijacker, isReaderFrom}
+
+		if isReaderFrom {
+			w.(io.ReaderFrom).ReadFrom(strings.NewReader("abc"))
+		}
+		http.NewResponseController(w).Flush()
+	}))
+
+	// a recorder only supports flushing
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, <-results, result{flusher: true})
+	assert.True(t, rec.Flushed)
+
+	// a real connection supports everything
+	srv := httptest.NewServer(h)
+	resp, err := http.Get(srv.URL)
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, <-results, result{true, true, true})
+	assert.Equal(t, string(body), "abc")
+
+	// wait for the request to be fully handled
+	srv.Close()
+
+	lines := writeLines(set)
+	assert.True(t, slices.Contains(lines, `http_server_requests_total{method="GET",code="2xx",route="unmatched"} 2`))
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	set := metrics.NewSet()
+	h := MiddlewareFor(set, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+			conn.Close()
+		}
+	}))
+
+	// hijacked connections aren't tracked by the server, so wait
+	// for the handler to return ourselves
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	<-done
+
+	lines := writeLines(set)
+	assert.True(t, slices.Contains(lines, `http_server_requests_total{method="GET",code="1xx",route="unmatched"} 1`))
+}
+
+func writeLines(set *metrics.Set) []string {
+	var b bytes.Buffer
+	set.WritePrometheusUnthrottled(&b)
+	return strings.Split(strings.TrimSpace(b.String()), "\n")
+}
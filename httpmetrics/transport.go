@@ -0,0 +1,144 @@
+package httpmetrics
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"go.withmatt.com/metrics"
+)
+
+// NewTransport returns an [http.RoundTripper] that instruments requests made
+// through next, recording the following metrics into set:
+//
+//   - http_client_requests_total{host, method, code}
+//   - http_client_requests_in_flight{host}
+//   - http_client_request_duration_seconds{host, method, code}
+//   - http_client_dns_duration_seconds{host}
+//   - http_client_connect_duration_seconds{host}
+//   - http_client_tls_handshake_duration_seconds{host}
+//   - http_client_time_to_first_byte_seconds{host}
+//
+// Where code is the status class, such as "2xx", or "error" if no response
+// was received. Request durations cover the time until the response headers
+// are received, not reading the body. Connection phases are only recorded
+// when a new connection is established.
+//
+// If next is nil, [http.DefaultTransport] is used. Only [WithDurationBuckets]
+// applies to a transport.
+func NewTransport(set *metrics.Set, next http.RoundTripper, opts ...Option) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	cfg := newConfig(opts)
+	return &transport{
+		next:         next,
+		requests:     set.NewUint64Vec("http_client_requests_total", "host", "method", "code"),
+		inFlight:     set.NewInt64Vec("http_client_requests_in_flight", "host"),
+		duration:     newObserverVec(set, "http_client_request_duration_seconds", cfg.durationBuckets, "host", "method", "code"),
+		dns:          newObserverVec(set, "http_client_dns_duration_seconds", cfg.durationBuckets, "host"),
+		connect:      newObserverVec(set, "http_client_connect_duration_seconds", cfg.durationBuckets, "host"),
+		tlsHandshake: newObserverVec(set, "http_client_tls_handshake_duration_seconds", cfg.durationBuckets, "host"),
+		firstByte:    newObserverVec(set, "http_client_time_to_first_byte_seconds", cfg.durationBuckets, "host"),
+	}
+}
+
+type transport struct {
+	next http.RoundTripper
+
+	requests     *metrics.Uint64Vec
+	inFlight     *metrics.Int64Vec
+	duration     observerVec
+	dns          observerVec
+	connect      observerVec
+	tlsHandshake observerVec
+	firstByte    observerVec
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	host := r.URL.Host
+	method := normalizeMethod(r.Method)
+
+	inFlight := t.inFlight.WithLabelValues(host)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	ct := &clientTrace{t: t, host: host, start: metrics.NewTimer(nil)}
+	r = r.WithContext(httptrace.WithClientTrace(r.Context(), ct.trace()))
+
+	resp, err := t.next.RoundTrip(r)
+
+	code := "error"
+	if err == nil {
+		code = statusClass(resp.StatusCode)
+	}
+	t.requests.WithLabelValues(host, method, code).Inc()
+	ct.start.ObserveDurationTo(t.duration(host, method, code))
+	return resp, err
+}
+
+// clientTrace tracks the timing of connection phases of a single request.
+type clientTrace struct {
+	t     *transport
+	host  string
+	start metrics.Timer
+
+	// hooks may be called concurrently when dialing multiple addresses
+	mu            sync.Mutex
+	dnsStart      metrics.Timer
+	connectStarts map[string]metrics.Timer
+	tlsStart      metrics.Timer
+}
+
+func (ct *clientTrace) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			ct.mu.Lock()
+			ct.dnsStart = metrics.NewTimer(nil)
+			ct.mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err != nil {
+				return
+			}
+			ct.mu.Lock()
+			start := ct.dnsStart
+			ct.mu.Unlock()
+			start.ObserveDurationTo(ct.t.dns(ct.host))
+		},
+		ConnectStart: func(network, addr string) {
+			ct.mu.Lock()
+			if ct.connectStarts == nil {
+				ct.connectStarts = make(map[string]metrics.Timer, 1)
+			}
+			ct.connectStarts[network+addr] = metrics.NewTimer(nil)
+			ct.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			ct.mu.Lock()
+			start, ok := ct.connectStarts[network+addr]
+			ct.mu.Unlock()
+			if ok && err == nil {
+				start.ObserveDurationTo(ct.t.connect(ct.host))
+			}
+		},
+		TLSHandshakeStart: func() {
+			ct.mu.Lock()
+			ct.tlsStart = metrics.NewTimer(nil)
+			ct.mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err != nil {
+				return
+			}
+			ct.mu.Lock()
+			start := ct.tlsStart
+			ct.mu.Unlock()
+			start.ObserveDurationTo(ct.t.tlsHandshake(ct.host))
+		},
+		GotFirstResponseByte: func() {
+			ct.start.ObserveDurationTo(ct.t.firstByte(ct.host))
+		},
+	}
+}
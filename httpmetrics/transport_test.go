@@ -0,0 +1,77 @@
+package httpmetrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics"
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	set := metrics.NewSet()
+	client := &http.Client{
+		Transport: NewTransport(set, srv.Client().Transport, WithDurationBuckets([]float64{60})),
+	}
+	for _, path := range []string{"/", "/", "/missing"} {
+		resp, err := client.Get(srv.URL + path)
+		assert.Nil(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	lines := writeLines(set)
+	for _, want := range []string{
+		`http_client_requests_total{host="` + host + `",method="GET",code="2xx"} 2`,
+		`http_client_requests_total{host="` + host + `",method="GET",code="4xx"} 1`,
+		`http_client_requests_in_flight{host="` + host + `"} 0`,
+		`http_client_request_duration_seconds_count{host="` + host + `",method="GET",code="2xx"} 2`,
+		`http_client_time_to_first_byte_seconds_count{host="` + host + `"} 3`,
+		// the connection is reused after the first request
+		`http_client_connect_duration_seconds_count{host="` + host + `"} 1`,
+		`http_client_tls_handshake_duration_seconds_count{host="` + host + `"} 1`,
+	} {
+		assert.True(t, slices.Contains(lines, want), assert.Sprintf("missing %s", want))
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	errFailed := errors.New("failed")
+	set := metrics.NewSet()
+	client := &http.Client{
+		Transport: NewTransport(set, roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errFailed
+		})),
+	}
+	_, err := client.Get("http://example.com/")
+	assert.ErrorIs(t, err, errFailed)
+
+	lines := writeLines(set)
+	for _, want := range []string{
+		`http_client_requests_total{host="example.com",method="GET",code="error"} 1`,
+		`http_client_request_duration_seconds_count{host="example.com",method="GET",code="error"} 1`,
+	} {
+		assert.True(t, slices.Contains(lines, want), assert.Sprintf("missing %s", want))
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
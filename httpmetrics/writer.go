@@ -0,0 +1,145 @@
+package httpmetrics
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter records the status code and number of bytes written
+// to an [http.ResponseWriter].
+type responseWriter struct {
+	w           http.ResponseWriter
+	code        int
+	wroteHeader bool
+	hijacked    bool
+	written     int64
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// informational responses may be written multiple times before
+	// the final status, except 101 which is final
+	if !w.wroteHeader && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		w.code = code
+		w.wroteHeader = true
+	}
+	w.w.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.implicitHeader()
+	n, err := w.w.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap allows [http.ResponseController] to reach the original
+// [http.ResponseWriter].
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.w
+}
+
+func (w *responseWriter) implicitHeader() {
+	if !w.wroteHeader {
+		w.code = http.StatusOK
+		w.wroteHeader = true
+	}
+}
+
+// status returns the final status code of the response.
+func (w *responseWriter) status() int {
+	switch {
+	case w.wroteHeader:
+		return w.code
+	case w.hijacked:
+		return http.StatusSwitchingProtocols
+	default:
+		// nothing was written, so net/http sends an implicit 200
+		return http.StatusOK
+	}
+}
+
+type flusher struct{ rw *responseWriter }
+
+func (f flusher) Flush() {
+	f.rw.implicitHeader()
+	f.rw.w.(http.Flusher).Flush()
+}
+
+type hijacker struct{ rw *responseWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := h.rw.w.(http.Hijacker).Hijack()
+	if err == nil {
+		h.rw.hijacked = true
+	}
+	return conn, brw, err
+}
+
+type readerFrom struct{ rw *responseWriter }
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	r.rw.implicitHeader()
+	n, err := r.rw.w.(io.ReaderFrom).ReadFrom(src)
+	r.rw.written += n
+	return n, err
+}
+
+// wrapResponseWriter returns rw exposing exactly the optional interfaces
+// the original [http.ResponseWriter] implements, so type assertions by
+// handlers behave the same as without instrumentation.
+func wrapResponseWriter(rw *responseWriter) http.ResponseWriter {
+	_, isFlusher := rw.w.(http.Flusher)
+	_, isHijacker := rw.w.(http.Hijacker)
+	_, isReaderFrom := rw.w.(io.ReaderFrom)
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+			readerFrom
+		}{rw, flusher{rw}, hijacker{rw}, readerFrom{rw}}
+	case isFlusher && isHijacker:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+		}{rw, flusher{rw}, hijacker{rw}}
+	case isFlusher && isReaderFrom:
+		return struct {
+			*responseWriter
+			flusher
+			readerFrom
+		}{rw, flusher{rw}, readerFrom{rw}}
+	case isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			hijacker
+			readerFrom
+		}{rw, hijacker{rw}, readerFrom{rw}}
+	case isFlusher:
+		return struct {
+			*responseWriter
+			flusher
+		}{rw, flusher{rw}}
+	case isHijacker:
+		return struct {
+			*responseWriter
+			hijacker
+		}{rw, hijacker{rw}}
+	case isReaderFrom:
+		return struct {
+			*responseWriter
+			readerFrom
+		}{rw, readerFrom{rw}}
+	default:
+		return rw
+	}
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import "strconv"
+
+// MetricWriter is a structured sink for typed metric values, independent of
+// the output format. [ExpfmtWriter] implements MetricWriter for the
+// Prometheus text exposition format, and other encoders can implement it to
+// receive the same values from a [MetricCollector].
+//
+// Constant tags are the concern of the MetricWriter, a MetricName only has
+// the tags of the metric itself.
+type MetricWriter interface {
+	// WriteGaugeUint64 writes a value that can go up and down.
+	WriteGaugeUint64(name MetricName, value uint64)
+	// WriteGaugeInt64 writes a value that can go up and down.
+	WriteGaugeInt64(name MetricName, value int64)
+	// WriteGaugeFloat64 writes a value that can go up and down.
+	WriteGaugeFloat64(name MetricName, value float64)
+	// WriteCounterUint64 writes a monotonically increasing value.
+	WriteCounterUint64(name MetricName, value uint64)
+	// WriteCounterFloat64 writes a monotonically increasing value.
+	WriteCounterFloat64(name MetricName, value float64)
+	// WriteHistogram writes a histogram of observations counted in ranges,
+	// where counts[i] is the count of observations within
+	// bounds[i] and bounds[i+1], so len(bounds) must be len(counts)+1.
+	// This is the layout of a [runtime/metrics.Float64Histogram].
+	WriteHistogram(name MetricName, bounds []float64, counts []uint64, sum float64)
+	// WriteSummary writes quantiles of observations, along with the count
+	// and sum of all observations.
+	WriteSummary(name MetricName, quantiles []Quantile, count uint64, sum float64)
+}
+
+// MetricCollector is a Collector which writes to any [MetricWriter], so it is
+// portable to any output format. Collect of a MetricCollector typically
+// calls CollectMetrics with its [ExpfmtWriter].
+//
+// All built-in Collectors are MetricCollectors, other than
+// [NewCachedCollector], which caches text output. [Set.Gather] reads the
+// typed values of a MetricCollector, rather than parsing its text output.
+type MetricCollector interface {
+	Collector
+	CollectMetrics(w MetricWriter)
+}
+
+// MetricCollectorFunc is an adapter to allow the use of ordinary functions as
+// MetricCollectors.
+type MetricCollectorFunc func(w MetricWriter)
+
+// Collect calls f(w).
+func (f MetricCollectorFunc) Collect(w ExpfmtWriter) {
+	f(w)
+}
+
+// CollectMetrics calls f(w).
+func (f MetricCollectorFunc) CollectMetrics(w MetricWriter) {
+	f(w)
+}
+
+var _ MetricWriter = ExpfmtWriter{}
+
+// WriteGaugeUint64 writes a full MetricName and uint64 value.
+func (w ExpfmtWriter) WriteGaugeUint64(name MetricName, value uint64) {
+	w.WriteMetricUint64(name, value)
+}
+
+// WriteGaugeInt64 writes a full MetricName and int64 value.
+func (w ExpfmtWriter) WriteGaugeInt64(name MetricName, value int64) {
+	w.WriteMetricInt64(name, value)
+}
+
+// WriteGaugeFloat64 writes a full MetricName and float64 value.
+func (w ExpfmtWriter) WriteGaugeFloat64(name MetricName, value float64) {
+	w.WriteMetricFloat64(name, value)
+}
+
+// WriteCounterUint64 writes a full MetricName and uint64 value.
+func (w ExpfmtWriter) WriteCounterUint64(name MetricName, value uint64) {
+	w.WriteMetricUint64(name, value)
+}
+
+// WriteCounterFloat64 writes a full MetricName and float64 value.
+func (w ExpfmtWriter) WriteCounterFloat64(name MetricName, value float64) {
+	w.WriteMetricFloat64(name, value)
+}
+
+// WriteHistogram writes a `vmrange` histogram, with a _bucket line for each
+// range with observations, followed by _sum and _count. Nothing is written
+// if there are no observations.
+func (w ExpfmtWriter) WriteHistogram(name MetricName, bounds []float64, counts []uint64, sum float64) {
+	if len(bounds) != len(counts)+1 {
+		panic("metrics: histogram must have one more bound than counts")
+	}
+
+	var totalCount uint64
+	var nonZero int
+	for _, count := range counts {
+		totalCount += count
+		if count > 0 {
+			nonZero++
+		}
+	}
+	if totalCount == 0 {
+		return
+	}
+
+	family := name.Family.String()
+	b := w.b
+
+	tagsSize := sizeOfTags(name.Tags, w.constantTags) + 1
+
+	const (
+		chunkVMRange = `_bucket{vmrange="`
+		chunkSum     = "_sum"
+		chunkCount   = "_count"
+	)
+
+	b.Grow(
+		(len(family)+len(chunkVMRange)+tagsSize+40)*nonZero +
+			len(family) + len(chunkSum) + tagsSize + 20 +
+			len(family) + len(chunkCount) + tagsSize + 20 +
+			64,
+	)
+
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+
+		b.WriteString(family)
+		b.WriteString(chunkVMRange)
+		b.Write(strconv.AppendFloat(b.AvailableBuffer(), bounds[i], 'g', -1, 64))
+		b.WriteString("...")
+		b.Write(strconv.AppendFloat(b.AvailableBuffer(), bounds[i+1], 'g', -1, 64))
+		b.WriteByte('"')
+		if len(w.constantTags) > 0 {
+			b.WriteByte(',')
+			b.WriteString(w.constantTags)
+		}
+		for _, tag := range name.Tags {
+			b.WriteByte(',')
+			writeTag(b, tag)
+		}
+		b.WriteString(`} `)
+		writeUint64(b, count)
+		b.WriteByte('\n')
+	}
+
+	w.writeSuffixed(name, chunkSum)
+	w.WriteFloat64(sum)
+	w.writeSuffixed(name, chunkCount)
+	w.WriteUint64(totalCount)
+}
+
+// WriteSummary writes a line for each quantile with a `quantile` tag,
+// followed by _sum and _count.
+func (w ExpfmtWriter) WriteSummary(name MetricName, quantiles []Quantile, count uint64, sum float64) {
+	labels := [1]Label{quantileLabel}
+	var values [1]Value
+	for _, q := range quantiles {
+		values[0] = UnsafeValue(strconv.FormatFloat(q.Quantile, 'g', -1, 64))
+		w.WriteMetricNameWithVariableTags(name, labels[:], values[:])
+		w.WriteFloat64(q.Value)
+	}
+
+	w.writeSuffixed(name, "_sum")
+	w.WriteFloat64(sum)
+	w.writeSuffixed(name, "_count")
+	w.WriteUint64(count)
+}
+
+// writeSuffixed writes the MetricName with a suffix appended to the family.
+func (w ExpfmtWriter) writeSuffixed(name MetricName, suffix string) {
+	b := w.b
+	b.WriteString(name.Family.String())
+	b.WriteString(suffix)
+	if len(w.constantTags) > 0 || name.hasTags() {
+		b.WriteByte('{')
+		writeTags(b, w.constantTags, name.Tags)
+		b.WriteByte('}')
+	}
+}
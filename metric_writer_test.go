@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestExpfmtWriterMetricWriter(t *testing.T) {
+	set := NewSet("env", "prod")
+	set.RegisterCollector(MetricCollectorFunc(func(w MetricWriter) {
+		w.WriteGaugeInt64(NewMetricName("temperature"), -3)
+		w.WriteCounterFloat64(NewMetricName("cpu_seconds_total", "mode", "user"), 1.5)
+		w.WriteHistogram(NewMetricName("latency"),
+			[]float64{0, 0.5, 1, 2},
+			[]uint64{2, 0, 1},
+			2.5,
+		)
+		// no observations writes nothing
+		w.WriteHistogram(NewMetricName("empty"), []float64{0, 1}, []uint64{0}, 0)
+		w.WriteSummary(NewMetricName("pause_seconds", "gc", "full"), []Quantile{
+			{Quantile: 0.5, Value: 0.1},
+			{Quantile: 1, Value: 0.25},
+		}, 4, 0.6)
+	}))
+
+	assertMarshal(t, set, []string{
+		`temperature{env="prod"} -3`,
+		`cpu_seconds_total{env="prod",mode="user"} 1.5`,
+		`latency_bucket{vmrange="0...0.5",env="prod"} 2`,
+		`latency_bucket{vmrange="1...2",env="prod"} 1`,
+		`latency_sum{env="prod"} 2.5`,
+		`latency_count{env="prod"} 3`,
+		`pause_seconds{env="prod",gc="full",quantile="0.5"} 0.1`,
+		`pause_seconds{env="prod",gc="full",quantile="1"} 0.25`,
+		`pause_seconds_sum{env="prod",gc="full"} 0.6`,
+		`pause_seconds_count{env="prod",gc="full"} 4`,
+	})
+
+	assert.Panics(t, func() {
+		NewTestingExpfmtWriter().WriteHistogram(NewMetricName("bad"), []float64{0}, []uint64{1}, 0)
+	})
+}
+
+// recordingWriter is a MetricWriter for a non-text format.
+type recordingWriter struct {
+	lines []string
+}
+
+func (r *recordingWriter) record(kind string, name MetricName, value any) {
+	r.lines = append(r.lines, fmt.Sprintf("%s %s %v", kind, name, value))
+}
+
+func (r *recordingWriter) WriteGaugeUint64(name MetricName, value uint64) {
+	r.record("gauge", name, value)
+}
+
+func (r *recordingWriter) WriteGaugeInt64(name MetricName, value int64) {
+	r.record("gauge", name, value)
+}
+
+func (r *recordingWriter) WriteGaugeFloat64(name MetricName, value float64) {
+	r.record("gauge", name, value)
+}
+
+func (r *recordingWriter) WriteCounterUint64(name MetricName, value uint64) {
+	r.record("counter", name, value)
+}
+
+func (r *recordingWriter) WriteCounterFloat64(name MetricName, value float64) {
+	r.record("counter", name, value)
+}
+
+func (r *recordingWriter) WriteHistogram(name MetricName, bounds []float64, counts []uint64, sum float64) {
+	r.record("histogram", name, sum)
+}
+
+func (r *recordingWriter) WriteSummary(name MetricName, quantiles []Quantile, count uint64, sum float64) {
+	r.record("summary", name, count)
+}
+
+func TestMetricCollectors(t *testing.T) {
+	for _, c := range []Collector{
+		NewGoInfoCollector(),
+		NewGoGCCollector(),
+		NewGoMemoryCollector(),
+		NewGoSchedCollector(),
+		NewGoCPUCollector(),
+		NewGoCgoCollector(),
+		NewGoSyncCollector(),
+		NewGoGodebugCollector(),
+		NewGoMemstatsCollector(),
+		NewGoMetricsCollector(),
+		NewSelfMetricsCollector(),
+	} {
+		mc, ok := c.(MetricCollector)
+		assert.True(t, ok, assert.Sprintf("%T", c))
+		var r recordingWriter
+		mc.CollectMetrics(&r)
+		assert.Greater(t, len(r.lines), 0, assert.Sprintf("%T", c))
+	}
+
+	// these may yield nothing, depending on the platform and environment
+	for _, c := range []Collector{
+		NewProcessMetricsCollector(),
+		NewCgroupCollector(),
+		NewPSICollector(),
+		NewHostCollector(),
+		NewTCPSocketCollector(),
+		NewSQLStatsCollector(nil),
+		NewExpvarCollector(),
+	} {
+		mc, ok := c.(MetricCollector)
+		assert.True(t, ok, assert.Sprintf("%T", c))
+		mc.CollectMetrics(&recordingWriter{})
+	}
+
+	var r recordingWriter
+	NewGoMemstatsCollector().(MetricCollector).CollectMetrics(&r)
+	var summaries int
+	for _, line := range r.lines {
+		if strings.HasPrefix(line, "summary go_gc_duration_seconds ") {
+			summaries++
+		}
+	}
+	assert.Equal(t, summaries, 1)
+}
+
+func TestRuntimeMetricTypes(t *testing.T) {
+	var r recordingWriter
+	NewGoGCCollector().(MetricCollector).CollectMetrics(&r)
+	// cumulative runtime/metrics samples are counters
+	assert.True(t, slices.ContainsFunc(r.lines, func(line string) bool {
+		return strings.HasPrefix(line, "counter go_gc_cycles_total_gc_cycles ")
+	}))
+	assert.True(t, slices.ContainsFunc(r.lines, func(line string) bool {
+		return strings.HasPrefix(line, "gauge go_gc_heap_goal_bytes ")
+	}))
+}
@@ -37,6 +37,40 @@ type namedMetric struct {
 	id     metricHash
 	name   MetricName
 	metric Metric
+	// counter is set for a Uint64 registered as a counter, such as with
+	// NewCounter, so it's gathered as a counter rather than a gauge.
+	counter bool
+}
+
+// matchesName reports if nm is the series name.
+func (nm *namedMetric) matchesName(name MetricName) bool {
+	return nm.name.Family == name.Family && tagsEqual(nm.name.Tags, name.Tags)
+}
+
+// matchesValues reports if nm is the series of a Vec with the labels and
+// values.
+func (nm *namedMetric) matchesValues(family Ident, labels []Label, values []string) bool {
+	if nm.name.Family != family || len(nm.name.Tags) != len(values) {
+		return false
+	}
+	for i, tag := range nm.name.Tags {
+		if tag.label != labels[i] || tag.value.v != values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func tagsEqual(a, b []Tag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].label.String() != b[i].label.String() || a[i].value != b[i].value {
+			return false
+		}
+	}
+	return true
 }
 
 // NewMetricName creates a new [MetricName] with the given family and optional tags.
@@ -70,9 +104,24 @@ func (n MetricName) hasTags() bool {
 	return len(n.Tags) > 0
 }
 
+// compareNamedMetrics orders metrics by family, then by their tags, so
+// series are written in a deterministic order.
 func compareNamedMetrics(a, b *namedMetric) int {
-	return cmp.Compare(
-		a.name.Family.String(),
-		b.name.Family.String(),
-	)
+	if c := cmp.Compare(a.name.Family.String(), b.name.Family.String()); c != 0 {
+		return c
+	}
+	return compareTags(a.name.Tags, b.name.Tags)
+}
+
+// compareTags orders tags by each label, then value, in order.
+func compareTags(a, b []Tag) int {
+	for i := range min(len(a), len(b)) {
+		if c := cmp.Or(
+			cmp.Compare(a[i].label.String(), b[i].label.String()),
+			cmp.Compare(a[i].value.String(), b[i].value.String()),
+		); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
 }
@@ -1,10 +1,78 @@
 package metrics
 
+import "context"
+
+// ProcessMetricsCollectorOption configures a [NewProcessMetricsCollector].
+type ProcessMetricsCollectorOption func(*processMetricsCollector)
+
+// WithProcessIOMetrics enables or disables I/O metrics from /proc/self/io.
+// Enabled by default. Linux only.
+func WithProcessIOMetrics(enabled bool) ProcessMetricsCollectorOption {
+	return func(c *processMetricsCollector) { c.io = enabled }
+}
+
+// WithProcessStatusMetrics enables or disables memory, thread and context
+// switch metrics from /proc/self/status. Enabled by default. Linux only.
+func WithProcessStatusMetrics(enabled bool) ProcessMetricsCollectorOption {
+	return func(c *processMetricsCollector) { c.status = enabled }
+}
+
+// WithProcessSmapsMetrics enables or disables memory mapping metrics from
+// /proc/self/smaps_rollup. Disabled by default, since reading it walks the
+// page tables of the process, which is expensive for large heaps. Linux only.
+func WithProcessSmapsMetrics(enabled bool) ProcessMetricsCollectorOption {
+	return func(c *processMetricsCollector) { c.smaps = enabled }
+}
+
 // NewProcessMetricsCollector is a Collector that yields process runtime metrics.
 // Metrics are prefixed with `process_`.
-func NewProcessMetricsCollector() Collector {
-	var c processMetricsCollector
+//
+// On Linux, the following are additionally yielded, see the options:
+//
+//   - process_io_read_bytes_total
+//   - process_io_written_bytes_total
+//   - process_io_read_syscalls_total
+//   - process_io_write_syscalls_total
+//   - process_io_storage_read_bytes_total
+//   - process_io_storage_written_bytes_total
+//   - process_resident_memory_peak_bytes
+//   - process_swap_bytes
+//   - process_threads
+//   - process_context_switches_total{kind="voluntary"|"nonvoluntary"}
+//   - process_proportional_memory_bytes
+//   - process_anonymous_memory_bytes
+//   - process_file_memory_bytes (proportional, Linux 5.9+)
+func NewProcessMetricsCollector(opts ...ProcessMetricsCollectorOption) Collector {
+	c := processMetricsCollector{
+		io:     true,
+		status: true,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
 	return &c
 }
 
-type processMetricsCollector struct{}
+type processMetricsCollector struct {
+	io     bool
+	status bool
+	smaps  bool
+}
+
+func (c *processMetricsCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *processMetricsCollector) CollectMetrics(w MetricWriter) {
+	c.collectMetrics(w)
+}
+
+// CollectContext reports which process metrics couldn't be read, such as
+// when /proc is restricted in a sandbox.
+func (c *processMetricsCollector) CollectContext(_ context.Context, w ExpfmtWriter) error {
+	return c.collectMetrics(w)
+}
+
+func (c *processMetricsCollector) collectMetricsContext(_ context.Context, w MetricWriter) error {
+	return c.collectMetrics(w)
+}
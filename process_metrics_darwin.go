@@ -2,6 +2,6 @@
 
 package metrics
 
-func (c *processMetricsCollector) Collect(w ExpfmtWriter) {
-	collectUnix(w)
+func (c *processMetricsCollector) collectMetrics(w MetricWriter) error {
+	return collectUnix(w)
 }
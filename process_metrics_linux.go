@@ -4,6 +4,7 @@ package metrics
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 )
@@ -39,21 +40,42 @@ type procStat struct {
 	Rss         int
 }
 
-func (c *processMetricsCollector) Collect(w ExpfmtWriter) {
-	collectUnix(w)
-	collectStatMetrics(w)
+func (c *processMetricsCollector) collectMetrics(w MetricWriter) error {
+	errs := []error{
+		collectUnix(w),
+		collectStatMetrics(w),
+	}
+	if c.io {
+		errs = append(errs, collectProcFile(w, "/proc/self/io", writeIOMetrics))
+	}
+	if c.status {
+		errs = append(errs, collectProcFile(w, "/proc/self/status", writeStatusMetrics))
+	}
+	if c.smaps {
+		errs = append(errs, collectProcFile(w, "/proc/self/smaps_rollup", writeSmapsRollupMetrics))
+	}
+	return errors.Join(errs...)
 }
 
-func collectStatMetrics(w ExpfmtWriter) {
+func collectProcFile(w MetricWriter, path string, write func(MetricWriter, []byte)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	write(w, data)
+	return nil
+}
+
+func collectStatMetrics(w MetricWriter) error {
 	data, err := os.ReadFile("/proc/self/stat")
 	if err != nil {
-		return
+		return err
 	}
 
 	// Search for the end of command.
 	n := bytes.LastIndex(data, []byte(") "))
 	if n < 0 {
-		return
+		return errors.New("metrics: invalid /proc/self/stat")
 	}
 	data = data[n+2:]
 
@@ -86,9 +108,88 @@ func collectStatMetrics(w ExpfmtWriter) {
 		&p.Rss,
 	)
 	if err != nil {
-		return
+		return fmt.Errorf("metrics: invalid /proc/self/stat: %w", err)
 	}
 
-	w.WriteLazyMetricUint64("process_resident_memory_bytes", uint64(p.Rss)*pageSizeBytes)
-	w.WriteLazyMetricUint64("process_virtual_memory_bytes", uint64(p.Vsize))
+	w.WriteGaugeUint64(NewMetricName("process_resident_memory_bytes"), uint64(p.Rss)*pageSizeBytes)
+	w.WriteGaugeUint64(NewMetricName("process_virtual_memory_bytes"), uint64(p.Vsize))
+	return nil
+}
+
+// writeIOMetrics writes metrics from /proc/self/io.
+func writeIOMetrics(w MetricWriter, data []byte) {
+	var rchar, wchar, syscr, syscw, readBytes, writeBytes uint64
+	parseProcFields(data, func(key string, value uint64) {
+		switch key {
+		case "rchar":
+			rchar = value
+		case "wchar":
+			wchar = value
+		case "syscr":
+			syscr = value
+		case "syscw":
+			syscw = value
+		case "read_bytes":
+			readBytes = value
+		case "write_bytes":
+			writeBytes = value
+		}
+	})
+
+	w.WriteCounterUint64(NewMetricName("process_io_read_bytes_total"), rchar)
+	w.WriteCounterUint64(NewMetricName("process_io_written_bytes_total"), wchar)
+	w.WriteCounterUint64(NewMetricName("process_io_read_syscalls_total"), syscr)
+	w.WriteCounterUint64(NewMetricName("process_io_write_syscalls_total"), syscw)
+	w.WriteCounterUint64(NewMetricName("process_io_storage_read_bytes_total"), readBytes)
+	w.WriteCounterUint64(NewMetricName("process_io_storage_written_bytes_total"), writeBytes)
+}
+
+// writeStatusMetrics writes metrics from /proc/self/status. VmRSS is already
+// written as process_resident_memory_bytes from /proc/self/stat.
+func writeStatusMetrics(w MetricWriter, data []byte) {
+	var hwm, swap, threads, voluntary, nonvoluntary uint64
+	parseProcFields(data, func(key string, value uint64) {
+		switch key {
+		case "VmHWM":
+			hwm = value
+		case "VmSwap":
+			swap = value
+		case "Threads":
+			threads = value
+		case "voluntary_ctxt_switches":
+			voluntary = value
+		case "nonvoluntary_ctxt_switches":
+			nonvoluntary = value
+		}
+	})
+
+	w.WriteGaugeUint64(NewMetricName("process_resident_memory_peak_bytes"), hwm)
+	w.WriteGaugeUint64(NewMetricName("process_swap_bytes"), swap)
+	w.WriteGaugeUint64(NewMetricName("process_threads"), threads)
+	w.WriteCounterUint64(NewMetricName("process_context_switches_total", "kind", "voluntary"), voluntary)
+	w.WriteCounterUint64(NewMetricName("process_context_switches_total", "kind", "nonvoluntary"), nonvoluntary)
+}
+
+// writeSmapsRollupMetrics writes metrics from /proc/self/smaps_rollup.
+func writeSmapsRollupMetrics(w MetricWriter, data []byte) {
+	var pss, anonymous, pssFile uint64
+	var hasPssFile bool
+	parseProcFields(data, func(key string, value uint64) {
+		switch key {
+		case "Pss":
+			pss = value
+		case "Anonymous":
+			anonymous = value
+		case "Pss_File":
+			// Linux 5.9+, which excludes shmem and tmpfs unlike Rss-Anonymous
+			pssFile = value
+			hasPssFile = true
+		}
+	})
+
+	w.WriteGaugeUint64(NewMetricName("process_proportional_memory_bytes"), pss)
+	w.WriteGaugeUint64(NewMetricName("process_anonymous_memory_bytes"), anonymous)
+	if hasPssFile {
+		w.WriteGaugeUint64(NewMetricName("process_file_memory_bytes"), pssFile)
+	}
 }
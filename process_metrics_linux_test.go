@@ -0,0 +1,96 @@
+//go:build linux
+
+package metrics
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func assertCollected(tb testing.TB, write func(w ExpfmtWriter), expected []string) {
+	tb.Helper()
+	w := NewTestingExpfmtWriter()
+	write(w)
+	lines := splitLines(strings.TrimSpace(w.Buffer().String()))
+	assert.LinesEqual(tb, lines, splitLines(strings.Join(expected, "\n")))
+}
+
+func TestProcessIOMetrics(t *testing.T) {
+	data := []byte("rchar: 3980\nwchar: 12\nsyscr: 9\nsyscw: 2\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n")
+	assertCollected(t, func(w ExpfmtWriter) { writeIOMetrics(w, data) }, []string{
+		"process_io_read_bytes_total 3980",
+		"process_io_written_bytes_total 12",
+		"process_io_read_syscalls_total 9",
+		"process_io_write_syscalls_total 2",
+		"process_io_storage_read_bytes_total 4096",
+		"process_io_storage_written_bytes_total 8192",
+	})
+}
+
+func TestProcessStatusMetrics(t *testing.T) {
+	data := []byte("Name:\ttest\nVmPeak:\t    3348 kB\nVmHWM:\t    1664 kB\nVmRSS:\t    1600 kB\nVmSwap:\t       2 kB\n" +
+		"Threads:\t7\nvoluntary_ctxt_switches:\t10\nnonvoluntary_ctxt_switches:\t3\n")
+	assertCollected(t, func(w ExpfmtWriter) { writeStatusMetrics(w, data) }, []string{
+		"process_resident_memory_peak_bytes 1703936",
+		"process_swap_bytes 2048",
+		"process_threads 7",
+		`process_context_switches_total{kind="voluntary"} 10`,
+		`process_context_switches_total{kind="nonvoluntary"} 3`,
+	})
+}
+
+func TestProcessSmapsRollupMetrics(t *testing.T) {
+	data := []byte("55d380f83000-7ffd18a91000 ---p 00000000 00:00 0                          [rollup]\n" +
+		"Rss:                1404 kB\nPss:                 466 kB\nPss_Anon:            104 kB\nPss_File:            300 kB\n" +
+		"Pss_Shmem:            62 kB\nAnonymous:           104 kB\n")
+	assertCollected(t, func(w ExpfmtWriter) { writeSmapsRollupMetrics(w, data) }, []string{
+		"process_proportional_memory_bytes 477184",
+		"process_anonymous_memory_bytes 106496",
+		"process_file_memory_bytes 307200",
+	})
+
+	// older kernels without Pss_File
+	data = []byte("Rss:                1404 kB\nPss:                 466 kB\nAnonymous:           104 kB\n")
+	assertCollected(t, func(w ExpfmtWriter) { writeSmapsRollupMetrics(w, data) }, []string{
+		"process_proportional_memory_bytes 477184",
+		"process_anonymous_memory_bytes 106496",
+	})
+}
+
+func TestProcessMetricsCollectorOptions(t *testing.T) {
+	collect := func(opts ...ProcessMetricsCollectorOption) string {
+		w := NewTestingExpfmtWriter()
+		NewProcessMetricsCollector(opts...).Collect(w)
+		return w.Buffer().String()
+	}
+
+	out := collect()
+	assert.True(t, strings.Contains(out, "process_threads "))
+	assert.False(t, strings.Contains(out, "process_proportional_memory_bytes "))
+
+	out = collect(
+		WithProcessIOMetrics(false),
+		WithProcessStatusMetrics(false),
+		WithProcessSmapsMetrics(true),
+	)
+	assert.False(t, strings.Contains(out, "process_io_"))
+	assert.False(t, strings.Contains(out, "process_threads "))
+	assert.True(t, strings.Contains(out, "process_proportional_memory_bytes "))
+
+	set := NewSet()
+	set.RegisterCollector(NewProcessMetricsCollector(WithProcessSmapsMetrics(true)))
+	set.WritePrometheusUnthrottled(io.Discard)
+}
+
+func TestProcessMetricsCollectContext(t *testing.T) {
+	c := NewProcessMetricsCollector(WithProcessSmapsMetrics(true)).(ContextCollector)
+	w := NewTestingExpfmtWriter()
+	assert.Nil(t, c.CollectContext(t.Context(), w))
+	assert.True(t, strings.Contains(w.Buffer().String(), "process_resident_memory_bytes "))
+
+	err := collectProcFile(w, "/proc/self/missing", writeIOMetrics)
+	assert.NotNil(t, err)
+}
@@ -2,4 +2,4 @@
 
 package metrics
 
-func (c *processMetricsCollector) Collect(w ExpfmtWriter) {}
+func (c *processMetricsCollector) collectMetrics(w MetricWriter) error { return nil }
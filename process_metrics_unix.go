@@ -3,6 +3,8 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"syscall"
@@ -13,33 +15,43 @@ import (
 
 var startTimeSeconds = float64(time.Now().UnixNano()) / 1e9
 
-func collectUnix(w ExpfmtWriter) {
-	w.WriteLazyMetricFloat64("process_start_time_seconds", startTimeSeconds)
+func collectUnix(w MetricWriter) error {
+	w.WriteGaugeFloat64(NewMetricName("process_start_time_seconds"), startTimeSeconds)
 
-	collectRusageUnix(w)
+	return collectRusageUnix(w)
 }
 
-func collectRusageUnix(w ExpfmtWriter) {
+func collectRusageUnix(w MetricWriter) error {
+	var errs []error
 	var rusage unix.Rusage
 
 	if err := unix.Getrusage(syscall.RUSAGE_SELF, &rusage); err == nil {
-		w.WriteLazyMetricDuration(
-			"process_cpu_seconds_total",
-			time.Duration(rusage.Stime.Nano()+rusage.Utime.Nano()),
+		w.WriteCounterFloat64(
+			NewMetricName("process_cpu_seconds_total"),
+			time.Duration(rusage.Stime.Nano()+rusage.Utime.Nano()).Seconds(),
 		)
+	} else {
+		errs = append(errs, fmt.Errorf("metrics: getrusage: %w", err))
 	}
 
 	if fds, err := getOpenFileCount(); err == nil {
-		w.WriteLazyMetricUint64("process_open_fds", fds)
+		w.WriteGaugeUint64(NewMetricName("process_open_fds"), fds)
+	} else {
+		errs = append(errs, err)
 	}
 
 	var rlimit syscall.Rlimit
 	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err == nil {
-		w.WriteLazyMetricUint64("process_max_fds", rlimit.Cur)
+		w.WriteGaugeUint64(NewMetricName("process_max_fds"), rlimit.Cur)
+	} else {
+		errs = append(errs, fmt.Errorf("metrics: getrlimit RLIMIT_NOFILE: %w", err))
 	}
 	if err := syscall.Getrlimit(syscall.RLIMIT_AS, &rlimit); err == nil {
-		w.WriteLazyMetricUint64("process_virtual_memory_max_bytes", rlimit.Cur)
+		w.WriteGaugeUint64(NewMetricName("process_virtual_memory_max_bytes"), rlimit.Cur)
+	} else {
+		errs = append(errs, fmt.Errorf("metrics: getrlimit RLIMIT_AS: %w", err))
 	}
+	return errors.Join(errs...)
 }
 
 func getOpenFileCount() (uint64, error) {
@@ -0,0 +1,64 @@
+//go:build linux
+
+package metrics
+
+import (
+	"bytes"
+	"io/fs"
+	"strconv"
+)
+
+// readUintFile reads a file containing a single unsigned integer, such as
+// those in cgroupfs.
+func readUintFile(fsys fs.FS, name string) (uint64, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(bytes.TrimSpace(data)), 10, 64)
+}
+
+// readKeyValues reads a file of "key value" lines, such as cgroup
+// memory.events or cpu.stat, calling fn for each line with an
+// unsigned integer value.
+func readKeyValues(fsys fs.FS, name string, fn func(key string, value uint64)) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	for line := range bytes.Lines(data) {
+		key, value, ok := bytes.Cut(bytes.TrimSpace(line), []byte(" "))
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(string(bytes.TrimSpace(value)), 10, 64)
+		if err != nil {
+			continue
+		}
+		fn(string(key), v)
+	}
+	return nil
+}
+
+// parseProcFields parses "Key: value" lines, such as /proc/self/status or
+// /proc/self/io, calling fn for each line with an unsigned integer value.
+// Values in kB are converted to bytes.
+func parseProcFields(data []byte, fn func(key string, value uint64)) {
+	for line := range bytes.Lines(data) {
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		value = bytes.TrimSpace(value)
+		var scale uint64 = 1
+		if v, ok := bytes.CutSuffix(value, []byte(" kB")); ok {
+			value = bytes.TrimSpace(v)
+			scale = 1024
+		}
+		v, err := strconv.ParseUint(string(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		fn(string(key), v*scale)
+	}
+}
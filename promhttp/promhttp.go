@@ -18,8 +18,11 @@ For example:
 package promhttp
 
 import (
+	"bytes"
+	"context"
 	"io"
 	"net/http"
+	"sync"
 
 	"go.withmatt.com/metrics"
 )
@@ -27,42 +30,104 @@ import (
 // ContentType is the HTTP Content-Type header for this format.
 const ContentType = "text/plain; version=0.0.4"
 
-// Handler returns an http.Handler for the global metrics Set.
+// Handler returns an http.Handler for the global metrics Set. The request's
+// context bounds parallel Collectors, see [metrics.WithParallelCollectors].
 func Handler() http.Handler {
-	return handler(metrics.WritePrometheus)
+	return contextHandler(metrics.WritePrometheusContext)
 }
 
-// HandlerFor returns an http.Handler for a specific metrics Set.
+// HandlerFor returns an http.Handler for a specific metrics Set. The request's
+// context bounds parallel Collectors, see [metrics.WithParallelCollectors].
 func HandlerFor(set *metrics.Set) http.Handler {
-	return handler(set.WritePrometheus)
+	return contextHandler(set.WritePrometheusContext)
+}
+
+// SortedHandler returns an http.Handler for the global metrics Set, with
+// families merged across children Sets. The request's context bounds
+// parallel Collectors.
+// See [metrics.Set.WritePrometheusSorted].
+func SortedHandler() http.Handler {
+	return contextHandler(metrics.WritePrometheusSortedContext)
+}
+
+// SortedHandlerFor returns an http.Handler for a specific metrics Set, with
+// families merged across children Sets. The request's context bounds
+// parallel Collectors.
+// See [metrics.Set.WritePrometheusSorted].
+func SortedHandlerFor(set *metrics.Set) http.Handler {
+	return contextHandler(set.WritePrometheusSortedContext)
+}
+
+// StreamingHandler returns an http.Handler for the global metrics Set, which
+// writes the response in chunks rather than buffering it whole. The
+// request's context bounds parallel Collectors.
+// See [metrics.Set.WritePrometheusStreaming].
+func StreamingHandler() http.Handler {
+	return contextHandler(metrics.WritePrometheusStreamingContext)
+}
+
+// StreamingHandlerFor returns an http.Handler for a specific metrics Set,
+// which writes the response in chunks rather than buffering it whole. The
+// request's context bounds parallel Collectors.
+// See [metrics.Set.WritePrometheusStreaming].
+func StreamingHandlerFor(set *metrics.Set) http.Handler {
+	return contextHandler(set.WritePrometheusStreamingContext)
 }
 
 // AnnotatedHandler returns an http.Handler for the global metrics Set and will
 // add HELP and TYPE annotations according to the [Mapping].
 func AnnotatedHandler(m Mapping) http.Handler {
-	return annotationHandler(metrics.WritePrometheus, m)
+	return annotationHandler(metrics.WritePrometheusContext, m)
 }
 
 // AnnotatedHandlerFor returns an http.Handler for a specific metrics Set and will
 // add HELP and TYPE annotations according to the [Mapping].
 func AnnotatedHandlerFor(set *metrics.Set, m Mapping) http.Handler {
-	return annotationHandler(set.WritePrometheus, m)
+	return annotationHandler(set.WritePrometheusContext, m)
 }
 
-type writerFunc func(w io.Writer) (int, error)
+type contextWriterFunc func(ctx context.Context, w io.Writer) (int, error)
 
-func handler(writePrometheus writerFunc) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+func contextHandler(writePrometheus contextWriterFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", ContentType)
-		writePrometheus(w)
+		writePrometheus(r.Context(), w)
 	})
 }
 
-func annotationHandler(writePrometheus writerFunc, m Mapping) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+// bufferPool holds the buffers for annotated responses, so repeated scrapes
+// reuse them instead of allocating.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer is the largest buffer kept in bufferPool, larger buffers
+// are dropped.
+const maxPooledBuffer = 64 << 20
+
+func putBuffer(bb *bytes.Buffer) {
+	if bb.Cap() > maxPooledBuffer {
+		return
+	}
+	bb.Reset()
+	bufferPool.Put(bb)
+}
+
+func annotationHandler(writePrometheus contextWriterFunc, m Mapping) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", ContentType)
-		tr := NewTransformer(m)
-		writePrometheus(tr)
-		io.Copy(w, tr)
+
+		in := bufferPool.Get().(*bytes.Buffer)
+		out := bufferPool.Get().(*bytes.Buffer)
+		defer func() {
+			putBuffer(in)
+			putBuffer(out)
+		}()
+
+		// the same as a Transformer, without the pipes
+		writePrometheus(r.Context(), in)
+		out.Grow(in.Len())
+		transform(out, in.Bytes(), m)
+		w.Write(out.Bytes())
 	})
 }
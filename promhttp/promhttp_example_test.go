@@ -35,3 +35,22 @@ func ExampleAnnotatedHandler() {
 	// Export all globally registered metrics with our mapping.
 	http.Handle("/metrics", promhttp.AnnotatedHandler(mapping))
 }
+
+func ExampleSortedHandlerFor() {
+	set := metrics.NewSet()
+	sv := set.NewSetVec("shard")
+	sv.NewCounter("requests_total", "a").Inc()
+	sv.NewCounter("requests_total", "b").Inc()
+
+	// Export all metrics with each family written contiguously, as
+	// required by strict scrapers, without the cost of a Transformer.
+	http.Handle("/metrics", promhttp.SortedHandlerFor(set))
+}
+
+func ExampleStreamingHandlerFor() {
+	set := metrics.NewSet()
+	set.NewCounter("foo").Inc()
+
+	// Export a large Set without holding the whole response in memory.
+	http.Handle("/metrics", promhttp.StreamingHandlerFor(set))
+}
@@ -0,0 +1,65 @@
+package promhttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.withmatt.com/metrics"
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestAnnotatedHandlerFor(t *testing.T) {
+	set := metrics.NewSet()
+	set.NewCounter("requests_total", "code", "500").Inc()
+	set.NewCounter("requests_total", "code", "200").Add(2)
+	set.NewFixedHistogram("size", []float64{1}).Update(1)
+
+	mapping := Mapping{
+		"requests_total": {Type: Counter, Help: "Requests."},
+		"size":           {Type: Histogram},
+	}
+
+	// the handler matches a Transformer
+	tr := NewTransformer(mapping)
+	set.WritePrometheus(tr)
+	var want bytes.Buffer
+	want.ReadFrom(tr)
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		AnnotatedHandlerFor(set, mapping).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(t, rec.Header().Get("Content-Type"), ContentType)
+		assert.Equal(t, rec.Body.String(), want.String())
+	}
+}
+
+func TestHandlersRequestContext(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	set := metrics.NewSet()
+	set.NewCounter("foo").Inc()
+	set.RegisterCollector(metrics.CollectorFunc(func(w metrics.ExpfmtWriter) {
+		<-unblock
+	}))
+	set.SetCollectOptions(metrics.WithParallelCollectors())
+
+	// a hung Collector is dropped once the request is done
+	for _, h := range []http.Handler{
+		HandlerFor(set),
+		SortedHandlerFor(set),
+		StreamingHandlerFor(set),
+		AnnotatedHandlerFor(set, nil),
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequestWithContext(ctx, "GET", "/metrics", nil))
+		cancel()
+		assert.True(t, strings.HasSuffix(rec.Body.String(), "foo 1\n"))
+	}
+}
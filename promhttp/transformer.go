@@ -107,10 +107,42 @@ func (t *Transformer) Write(p []byte) (n int, err error) {
 }
 
 func handleTransform(in *io.PipeReader, out *io.PipeWriter, mapping Mapping) {
-	var lines []string
-	scanner := bufio.NewScanner(in)
-	for scanner.Scan() {
-		line := scanner.Text()
+	data, err := io.ReadAll(in)
+	if err != nil {
+		out.CloseWithError(err)
+		return
+	}
+
+	buf := bufio.NewWriter(out)
+	transform(buf, data, mapping)
+	// flush our buffer and close our pipe writer
+	out.CloseWithError(buf.Flush())
+}
+
+// linesPool holds the slices of lines used to sort metrics by family.
+var linesPool = sync.Pool{
+	New: func() any { return new([]string) },
+}
+
+type stringWriter interface {
+	io.StringWriter
+	io.ByteWriter
+}
+
+// transform writes the metric lines of data to out sorted by family, with
+// HELP and TYPE annotations from the mapping before each family.
+func transform(out stringWriter, data []byte, mapping Mapping) {
+	linesp := linesPool.Get().(*[]string)
+	defer func() {
+		clear(*linesp)
+		*linesp = (*linesp)[:0]
+		linesPool.Put(linesp)
+	}()
+
+	lines := *linesp
+	// lines are all sliced from a single string of data
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimRight(line, "\r\n")
 
 		// skip empty lines
 		if len(line) == 0 {
@@ -127,42 +159,30 @@ func handleTransform(in *io.PipeReader, out *io.PipeWriter, mapping Mapping) {
 			continue
 		}
 
-		// Maintain lines in sorted order as they are read.
-		idx, _ := slices.BinarySearchFunc(lines, line, compareLines)
-		lines = slices.Insert(lines, idx, line)
-	}
-
-	if err := scanner.Err(); err != nil {
-		out.CloseWithError(err)
-		return
+		lines = append(lines, line)
 	}
-
-	buf := bufio.NewWriter(out)
-
-	defer func() {
-		// flush our buffer and close our pipe writer
-		out.CloseWithError(buf.Flush())
-	}()
+	slices.SortFunc(lines, compareLines)
+	*linesp = lines
 
 	var lastFamily string
 	for _, line := range lines {
 		family, desc := mapping.get(getFamily(line))
 		if family != lastFamily {
 			lastFamily = family
-			buf.WriteString("# HELP ")
-			buf.WriteString(family)
+			out.WriteString("# HELP ")
+			out.WriteString(family)
 			if desc.Help != "" {
-				buf.WriteByte(' ')
-				buf.WriteString(desc.Help)
+				out.WriteByte(' ')
+				out.WriteString(desc.Help)
 			}
-			buf.WriteString("\n# TYPE ")
-			buf.WriteString(family)
-			buf.WriteByte(' ')
-			buf.WriteString(desc.Type.String())
-			buf.WriteByte('\n')
+			out.WriteString("\n# TYPE ")
+			out.WriteString(family)
+			out.WriteByte(' ')
+			out.WriteString(desc.Type.String())
+			out.WriteByte('\n')
 		}
-		buf.WriteString(line)
-		buf.WriteByte('\n')
+		out.WriteString(line)
+		out.WriteByte('\n')
 	}
 }
 
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"io/fs"
+	"os"
+)
+
+// PSICollectorOption configures a [NewPSICollector].
+type PSICollectorOption func(*psiCollector)
+
+// WithPSIFS reads pressure files from fsys instead of the root filesystem.
+// fsys is expected to contain proc/pressure, and proc/self/cgroup and
+// sys/fs/cgroup for the cgroup pressure, and is primarily useful for testing.
+func WithPSIFS(fsys fs.FS) PSICollectorOption {
+	return func(c *psiCollector) { c.fsys = fsys }
+}
+
+type psiCollector struct {
+	fsys fs.FS
+}
+
+// NewPSICollector is a [MetricCollector] that yields pressure stall information
+// (PSI) for cpu, memory and io, both for the whole system from /proc/pressure
+// and for the cgroup the process runs in:
+//
+//   - pressure_stall_ratio{resource, kind, window}
+//   - pressure_stall_seconds_total{resource, kind}
+//   - cgroup_pressure_stall_ratio{resource, kind, window}
+//   - cgroup_pressure_stall_seconds_total{resource, kind}
+//
+// Where kind is "some", the share of time at least one task was stalled on
+// the resource, or "full", the share of time all tasks were stalled. Ratios
+// are averaged over a window of "10s", "60s" or "300s".
+//
+// Cgroup pressure requires cgroup v2. This is only supported on Linux, and
+// yields nothing elsewhere, or if PSI isn't enabled.
+//
+// See https://docs.kernel.org/accounting/psi.html
+func NewPSICollector(opts ...PSICollectorOption) Collector {
+	c := &psiCollector{
+		fsys: os.DirFS("/"),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *psiCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
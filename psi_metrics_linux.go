@@ -0,0 +1,142 @@
+//go:build linux
+
+package metrics
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strconv"
+	"time"
+)
+
+var psiResources = [...]string{"cpu", "memory", "io"}
+
+// psiNames are the metric names for each resource, kind and window.
+type psiNames struct {
+	ratio [len(psiResources)][2][3]MetricName
+	total [len(psiResources)][2]MetricName
+}
+
+var (
+	psiSystemNames = makePSINames("pressure_")
+	psiCgroupNames = makePSINames("cgroup_pressure_")
+)
+
+func makePSINames(prefix string) *psiNames {
+	ratio := MustIdent(prefix + "stall_ratio")
+	total := MustIdent(prefix + "stall_seconds_total")
+
+	var names psiNames
+	for r, resource := range psiResources {
+		for k, kind := range [...]string{"some", "full"} {
+			for i, window := range [...]string{"10s", "60s", "300s"} {
+				names.ratio[r][k][i] = MetricName{
+					Family: ratio,
+					Tags:   MustTags("resource", resource, "kind", kind, "window", window),
+				}
+			}
+			names.total[r][k] = MetricName{
+				Family: total,
+				Tags:   MustTags("resource", resource, "kind", kind),
+			}
+		}
+	}
+	return &names
+}
+
+func (c *psiCollector) CollectMetrics(w MetricWriter) {
+	c.collect(w, "proc/pressure", "", psiSystemNames)
+
+	if paths, err := readCgroupPaths(c.fsys); err == nil {
+		if dir, ok := cgroupV2Dir(c.fsys, paths); ok {
+			c.collect(w, dir, ".pressure", psiCgroupNames)
+		}
+	}
+}
+
+// collect writes the pressure of each resource from files in dir.
+func (c *psiCollector) collect(w MetricWriter, dir, suffix string, names *psiNames) {
+	var lines [len(psiResources)][2]psiLine
+	for r, resource := range psiResources {
+		data, err := fs.ReadFile(c.fsys, path.Join(dir, resource+suffix))
+		if err != nil {
+			continue
+		}
+		for line := range bytes.Lines(data) {
+			var p psiLine
+			if p.parse(line) {
+				lines[r][p.kind] = p
+			}
+		}
+	}
+
+	// write each family together
+	for r := range lines {
+		for k, p := range lines[r] {
+			if p.ok {
+				for i, avg := range p.avg {
+					w.WriteGaugeFloat64(names.ratio[r][k][i], avg/100)
+				}
+			}
+		}
+	}
+	for r := range lines {
+		for k, p := range lines[r] {
+			if p.ok {
+				w.WriteCounterFloat64(names.total[r][k], (time.Duration(p.total) * time.Microsecond).Seconds())
+			}
+		}
+	}
+}
+
+// psiLine is a line of a pressure file, such as:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+type psiLine struct {
+	// kind is 0 for "some" and 1 for "full"
+	kind int
+	// avg is the percentage of time stalled over 10s, 60s and 300s
+	avg [3]float64
+	// total is the time stalled in microseconds
+	total uint64
+	// ok is set once parsed
+	ok bool
+}
+
+func (p *psiLine) parse(line []byte) bool {
+	fields := bytes.Fields(line)
+	if len(fields) != 5 {
+		return false
+	}
+	switch string(fields[0]) {
+	case "some":
+		p.kind = 0
+	case "full":
+		p.kind = 1
+	default:
+		return false
+	}
+	for i, prefix := range [...]string{"avg10=", "avg60=", "avg300="} {
+		v, ok := bytes.CutPrefix(fields[i+1], []byte(prefix))
+		if !ok {
+			return false
+		}
+		avg, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return false
+		}
+		p.avg[i] = avg
+	}
+	v, ok := bytes.CutPrefix(fields[4], []byte("total="))
+	if !ok {
+		return false
+	}
+	total, err := strconv.ParseUint(string(v), 10, 64)
+	if err != nil {
+		return false
+	}
+	p.total = total
+	p.ok = true
+	return true
+}
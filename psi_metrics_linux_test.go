@@ -0,0 +1,70 @@
+//go:build linux
+
+package metrics
+
+import (
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPSICollector(t *testing.T) {
+	fsys := fstest.MapFS{
+		"proc/pressure/cpu": {Data: []byte(
+			"some avg10=1.50 avg60=0.25 avg300=0.00 total=2500000\n" +
+				"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")},
+		"proc/pressure/memory": {Data: []byte(
+			"some avg10=0.00 avg60=0.00 avg300=0.00 total=100\n" +
+				"full avg10=0.00 avg60=0.00 avg300=0.00 total=50\n")},
+		// io is missing, such as when PSI is disabled
+		"proc/self/cgroup":                     {Data: []byte("0::/app\n")},
+		"sys/fs/cgroup/cgroup.controllers":     {Data: []byte("cpu memory io\n")},
+		"sys/fs/cgroup/app/cpu.pressure":       {Data: []byte("some avg10=50.00 avg60=10.00 avg300=1.00 total=1000000\nfull avg10=25.00 avg60=5.00 avg300=0.50 total=500000\n")},
+		"sys/fs/cgroup/app/memory.pressure":    {Data: []byte("garbage\n")},
+		"sys/fs/cgroup/app/io.pressure":        {Data: []byte("some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")},
+		"sys/fs/cgroup/app/unrelated.pressure": {Data: []byte("some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")},
+	}
+
+	set := NewSet()
+	set.RegisterCollector(NewPSICollector(WithPSIFS(fsys)))
+	assertMarshal(t, set, []string{
+		`pressure_stall_ratio{resource="cpu",kind="some",window="10s"} 0.015`,
+		`pressure_stall_ratio{resource="cpu",kind="some",window="60s"} 0.0025`,
+		`pressure_stall_ratio{resource="cpu",kind="some",window="300s"} 0`,
+		`pressure_stall_ratio{resource="cpu",kind="full",window="10s"} 0`,
+		`pressure_stall_ratio{resource="cpu",kind="full",window="60s"} 0`,
+		`pressure_stall_ratio{resource="cpu",kind="full",window="300s"} 0`,
+		`pressure_stall_ratio{resource="memory",kind="some",window="10s"} 0`,
+		`pressure_stall_ratio{resource="memory",kind="some",window="60s"} 0`,
+		`pressure_stall_ratio{resource="memory",kind="some",window="300s"} 0`,
+		`pressure_stall_ratio{resource="memory",kind="full",window="10s"} 0`,
+		`pressure_stall_ratio{resource="memory",kind="full",window="60s"} 0`,
+		`pressure_stall_ratio{resource="memory",kind="full",window="300s"} 0`,
+		`pressure_stall_seconds_total{resource="cpu",kind="some"} 2.5`,
+		`pressure_stall_seconds_total{resource="cpu",kind="full"} 0`,
+		`pressure_stall_seconds_total{resource="memory",kind="some"} 0.0001`,
+		`pressure_stall_seconds_total{resource="memory",kind="full"} 5e-05`,
+		`cgroup_pressure_stall_ratio{resource="cpu",kind="some",window="10s"} 0.5`,
+		`cgroup_pressure_stall_ratio{resource="cpu",kind="some",window="60s"} 0.1`,
+		`cgroup_pressure_stall_ratio{resource="cpu",kind="some",window="300s"} 0.01`,
+		`cgroup_pressure_stall_ratio{resource="cpu",kind="full",window="10s"} 0.25`,
+		`cgroup_pressure_stall_ratio{resource="cpu",kind="full",window="60s"} 0.05`,
+		`cgroup_pressure_stall_ratio{resource="cpu",kind="full",window="300s"} 0.005`,
+		`cgroup_pressure_stall_ratio{resource="io",kind="some",window="10s"} 0`,
+		`cgroup_pressure_stall_ratio{resource="io",kind="some",window="60s"} 0`,
+		`cgroup_pressure_stall_ratio{resource="io",kind="some",window="300s"} 0`,
+		`cgroup_pressure_stall_seconds_total{resource="cpu",kind="some"} 1`,
+		`cgroup_pressure_stall_seconds_total{resource="cpu",kind="full"} 0.5`,
+		`cgroup_pressure_stall_seconds_total{resource="io",kind="some"} 0`,
+	})
+}
+
+func TestPSICollectorMissing(t *testing.T) {
+	set := NewSet()
+	set.RegisterCollector(NewPSICollector(WithPSIFS(fstest.MapFS{})))
+	assertMarshal(t, set, nil)
+
+	set = NewSet()
+	set.RegisterCollector(NewPSICollector())
+	set.WritePrometheusUnthrottled(io.Discard)
+}
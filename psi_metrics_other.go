@@ -0,0 +1,5 @@
+//go:build !linux
+
+package metrics
+
+func (c *psiCollector) CollectMetrics(w MetricWriter) {}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// maxSmallScrapeBuffer is the largest buffer of smallScrapeBufferPool,
+	// which are used for streaming and the output of single Collectors.
+	// Streaming is bounded by the size of these buffers, so they are kept
+	// apart from the buffers of whole renders.
+	maxSmallScrapeBuffer = 4 * streamChunkSize
+	// maxPooledScrapeBuffer is the largest buffer kept for reuse, larger
+	// buffers are dropped.
+	maxPooledScrapeBuffer = 64 << 20
+)
+
+// scrapeBufferPool and smallScrapeBufferPool hold the buffers metrics are
+// rendered into, so repeated scrapes reuse them instead of allocating.
+var scrapeBufferPool, smallScrapeBufferPool sync.Pool
+
+// scrapeBufferStats are exported by [NewSelfMetricsCollector].
+var scrapeBufferStats struct {
+	// gets is the number of buffers taken from the pool
+	gets atomic.Uint64
+	// allocations is the number of new buffers, when the pool was empty
+	allocations atomic.Uint64
+	// grows is the number of pooled buffers too small for their size hint
+	grows atomic.Uint64
+}
+
+// getScrapeBuffer returns an empty buffer with a capacity of at least
+// sizeHint.
+func getScrapeBuffer(sizeHint int) *bytes.Buffer {
+	scrapeBufferStats.gets.Add(1)
+	pool := &scrapeBufferPool
+	if sizeHint <= maxSmallScrapeBuffer {
+		pool = &smallScrapeBufferPool
+	}
+	bb, ok := pool.Get().(*bytes.Buffer)
+	if !ok {
+		scrapeBufferStats.allocations.Add(1)
+		return bytes.NewBuffer(make([]byte, 0, sizeHint))
+	}
+	if bb.Cap() < sizeHint {
+		scrapeBufferStats.grows.Add(1)
+		bb.Grow(sizeHint)
+	}
+	return bb
+}
+
+// putScrapeBuffer returns bb to the pool of its size, unless it is larger
+// than maxPooledScrapeBuffer.
+func putScrapeBuffer(bb *bytes.Buffer) {
+	bb.Reset()
+	switch size := bb.Cap(); {
+	case size <= maxSmallScrapeBuffer:
+		smallScrapeBufferPool.Put(bb)
+	case size <= maxPooledScrapeBuffer:
+		scrapeBufferPool.Put(bb)
+	}
+}
+
+// writeSizeHint is the size to allocate for rendering s, based on the size
+// of its previous output.
+func (s *Set) writeSizeHint() int {
+	// leave headroom for growth since the last render
+	hint := int(s.lastWriteSize.Load())
+	return max(minimumWriteBuffer, hint+hint/8)
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestScrapeBufferSizeHint(t *testing.T) {
+	set := NewSet()
+	assert.Equal(t, set.writeSizeHint(), minimumWriteBuffer)
+
+	vec := set.NewUint64Vec("requests_total", "id")
+	for i := range 5000 {
+		vec.WithLabelValues(strconv.Itoa(i)).Inc()
+	}
+	var want bytes.Buffer
+	set.WritePrometheus(&want)
+	assert.Equal(t, set.lastWriteSize.Load(), int64(want.Len()))
+	assert.Greater(t, set.writeSizeHint(), want.Len())
+
+	// a pooled buffer is at least the size hint
+	bb := getScrapeBuffer(set.writeSizeHint())
+	assert.True(t, bb.Cap() >= set.writeSizeHint())
+	assert.Equal(t, bb.Len(), 0)
+	putScrapeBuffer(bb)
+
+	gets := scrapeBufferStats.gets.Load()
+	n, err := set.WritePrometheus(io.Discard)
+	assert.Nil(t, err)
+	assert.Equal(t, n, want.Len())
+	assert.Equal(t, scrapeBufferStats.gets.Load(), gets+1)
+
+	w := NewTestingExpfmtWriter()
+	NewSelfMetricsCollector().Collect(w)
+	assert.True(t, bytes.Contains(w.Buffer().Bytes(), []byte("gometrics_scrape_buffer_gets_total ")))
+	assert.True(t, bytes.Contains(w.Buffer().Bytes(), []byte("gometrics_scrape_buffer_allocations_total ")))
+}
+
+func TestScrapeBufferPools(t *testing.T) {
+	// a large render isn't reused for streaming
+	large := getScrapeBuffer(maxSmallScrapeBuffer + 1)
+	putScrapeBuffer(large)
+	for range 10 {
+		bb := getScrapeBuffer(streamChunkSize)
+		assert.True(t, bb != large)
+		assert.True(t, bb.Cap() <= maxSmallScrapeBuffer)
+		putScrapeBuffer(bb)
+	}
+
+	// oversized buffers are dropped
+	huge := bytes.NewBuffer(make([]byte, 0, maxPooledScrapeBuffer+1))
+	putScrapeBuffer(huge)
+	for range 10 {
+		bb := getScrapeBuffer(maxSmallScrapeBuffer + 1)
+		assert.True(t, bb != huge)
+		putScrapeBuffer(bb)
+	}
+}
+
+func BenchmarkWritePrometheusPooled(b *testing.B) {
+	set := NewSet()
+	vec := set.NewUint64Vec("requests_total", "id")
+	for i := range 1000 {
+		vec.WithLabelValues(strconv.Itoa(i)).Inc()
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		set.WritePrometheusUnthrottled(io.Discard)
+	}
+}
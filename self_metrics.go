@@ -4,17 +4,26 @@ import "weak"
 
 type selfMetricsCollector struct{}
 
-// NewSelfMetricsCollector is a Collector that yields our own runtime metrics.
-// Metrics are prefixed with `gometrics_`.
+// NewSelfMetricsCollector is a [MetricCollector] that yields our own runtime
+// metrics. Metrics are prefixed with `gometrics_`.
 func NewSelfMetricsCollector() Collector {
 	return &selfMetricsCollector{}
 }
 
-func (*selfMetricsCollector) Collect(w ExpfmtWriter) {
+func (c *selfMetricsCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (*selfMetricsCollector) CollectMetrics(w MetricWriter) {
 	var size uint64
 	rangeIdentCache(func(_ uint64, _ weak.Pointer[string]) bool {
 		size++
 		return true
 	})
-	w.WriteLazyMetricUint64("gometrics_ident_cache_size", size)
+	w.WriteGaugeUint64(NewMetricName("gometrics_ident_cache_size"), size)
+	w.WriteCounterUint64(NewMetricName("gometrics_hash_collisions_total"), hashCollisions.Load())
+	w.WriteCounterUint64(NewMetricName("gometrics_scrape_buffer_gets_total"), scrapeBufferStats.gets.Load())
+	w.WriteCounterUint64(NewMetricName("gometrics_scrape_buffer_allocations_total"), scrapeBufferStats.allocations.Load())
+	w.WriteCounterUint64(NewMetricName("gometrics_scrape_buffer_grows_total"), scrapeBufferStats.grows.Load())
+	w.WriteCounterUint64(NewMetricName("gometrics_collect_errors_total"), collectErrors.Load())
 }
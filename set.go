@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -69,6 +70,12 @@ func WritePrometheus(w io.Writer) (int, error) {
 	return defaultSet.WritePrometheus(w)
 }
 
+// WritePrometheusContext writes the global Set to io.Writer.
+// See [Set.WritePrometheusContext].
+func WritePrometheusContext(ctx context.Context, w io.Writer) (int, error) {
+	return defaultSet.WritePrometheusContext(ctx, w)
+}
+
 // Set is a collection of metrics. A single Set may have children Sets.
 //
 // [Set.WritePrometheus] must be called for exporting metrics from the set.
@@ -76,6 +83,9 @@ type Set struct {
 	// a Set gets assigned an id if it has constant tags, otherwise
 	// there is no uniqueness in a Set.
 	id metricHash
+	// idTags are the constant tags hashed into id, which identify the Set
+	// within its parent.
+	idTags []Tag
 
 	metrics syncx.SortedMap[metricHash, *namedMetric]
 
@@ -87,11 +97,18 @@ type Set struct {
 	// no constant tags.
 	unorderedSets syncx.Set[*Set]
 
-	collectors atomic.Pointer[[]Collector]
+	collectors    atomic.Pointer[[]*registeredCollector]
+	collectConfig atomic.Pointer[collectConfig]
+
+	// lastWriteSize is the size of the previous output, to size the next
+	// buffer it is rendered into.
+	lastWriteSize atomic.Int64
 
 	// constantTags are tags that are constant for all metrics in the set.
 	// Children sets inherit these base tags.
 	constantTags string
+	// constantTagList are the constantTags before being materialized.
+	constantTagList []Tag
 
 	ttl      time.Duration
 	lastUsed atomicx.Instant
@@ -104,7 +121,7 @@ type Set struct {
 // NewSet creates new set of metrics.
 func NewSet(constantTags ...string) *Set {
 	s := newSet()
-	s.setConstantTags("", constantTags...)
+	s.setConstantTags(nil, constantTags...)
 	return s
 }
 
@@ -125,7 +142,7 @@ func (s *Set) AppendConstantTags(constantTags ...string) {
 		return
 	}
 
-	s.setConstantTags(s.constantTags, constantTags...)
+	s.setConstantTags(s.constantTagList, constantTags...)
 
 	// We also need to descend into all children sets and append the new tags
 	// there as well.
@@ -142,13 +159,15 @@ func (s *Set) AppendConstantTags(constantTags ...string) {
 	})
 }
 
-func (s *Set) setConstantTags(previousConstantTags string, constantTags ...string) {
+func (s *Set) setConstantTags(previousConstantTags []Tag, constantTags ...string) {
 	s.metrics.Init(compareNamedMetrics)
-	s.constantTags = joinTags(previousConstantTags, MustTags(constantTags...)...)
+	tags := MustTags(constantTags...)
+	s.joinConstantTags(previousConstantTags, tags...)
 
 	// give the Set an id if it has new constant tags
 	if len(constantTags) > 0 {
 		s.id = getHashStrings("", constantTags)
+		s.idTags = tags
 	}
 }
 
@@ -171,7 +190,7 @@ func (s *Set) NewSet(constantTags ...string) *Set {
 	defer s.KeepAlive()
 
 	s2 := newSet()
-	s2.setConstantTags(s.constantTags, constantTags...)
+	s2.setConstantTags(s.constantTagList, constantTags...)
 
 	s.mustStoreSet(s2)
 	return s2
@@ -182,21 +201,28 @@ func (s *Set) UnregisterSet(set *Set) {
 	if set.id == emptyHash {
 		s.unorderedSets.Delete(set)
 	} else {
-		s.setsByHash.Delete(set.id)
+		s.setsByHash.CompareAndDelete(set.id, set)
 	}
 }
 
 // RegisterCollector registers one or more Collectors.
 // Registering the same collector more than once will panic.
 func (s *Set) RegisterCollector(cs ...Collector) {
-	var newValues []Collector
-	var oldValues *[]Collector
+	var newValues []*registeredCollector
+	var oldValues *[]*registeredCollector
+
+	registered := make([]*registeredCollector, len(cs))
+	for i, c := range cs {
+		registered[i] = newRegisteredCollector(c)
+	}
 
 	for {
 		oldValues = s.collectors.Load()
 		if oldValues != nil {
 			for _, c := range cs {
-				if slices.Contains(*oldValues, c) {
+				if slices.ContainsFunc(*oldValues, func(rc *registeredCollector) bool {
+					return rc.Collector == c
+				}) {
 					panic("metrics: Collector already registered")
 				}
 			}
@@ -204,7 +230,7 @@ func (s *Set) RegisterCollector(cs ...Collector) {
 		} else {
 			newValues = nil
 		}
-		newValues = append(newValues, cs...)
+		newValues = append(newValues, registered...)
 		if s.collectors.CompareAndSwap(oldValues, &newValues) {
 			return
 		}
@@ -214,15 +240,17 @@ func (s *Set) RegisterCollector(cs ...Collector) {
 // UnregisterCollector removes a previously registered Collector from
 // the Set.
 func (s *Set) UnregisterCollector(c Collector) {
-	var newValues []Collector
-	var oldValues *[]Collector
+	var newValues []*registeredCollector
+	var oldValues *[]*registeredCollector
 
 	for {
 		oldValues = s.collectors.Load()
 		if oldValues == nil {
 			return
 		}
-		idx := slices.Index(*oldValues, c)
+		idx := slices.IndexFunc(*oldValues, func(rc *registeredCollector) bool {
+			return rc.Collector == c
+		})
 		if idx == -1 {
 			return
 		}
@@ -242,10 +270,19 @@ func (s *Set) UnregisterCollector(c Collector) {
 // Metric writing and collecting is throttled by yielding the Go scheduler to
 // not starve CPU. Use WritePrometheusUnthrottled if you don't want that.
 func (s *Set) WritePrometheus(w io.Writer) (int, error) {
+	return s.WritePrometheusContext(context.Background(), w)
+}
+
+// WritePrometheusContext writes the metrics along with all children to the
+// io.Writer in Prometheus text exposition format, like [Set.WritePrometheus].
+//
+// ctx is passed to each [ContextCollector], and its deadline is the deadline
+// for Collectors run with [WithParallelCollectors].
+func (s *Set) WritePrometheusContext(ctx context.Context, w io.Writer) (int, error) {
 	if s.isExpired() {
 		return 0, ErrSetExpired
 	}
-	return s.writePrometheus(w, true)
+	return s.writePrometheus(ctx, w, true)
 }
 
 // WritePrometheusUnthrottled writes the metrics along with all children to the
@@ -256,27 +293,30 @@ func (s *Set) WritePrometheusUnthrottled(w io.Writer) (int, error) {
 	if s.isExpired() {
 		return 0, ErrSetExpired
 	}
-	return s.writePrometheus(w, false)
+	return s.writePrometheus(context.Background(), w, false)
 }
 
-func (s *Set) writePrometheus(w io.Writer, throttle bool) (int, error) {
+func (s *Set) writePrometheus(ctx context.Context, w io.Writer, throttle bool) (int, error) {
 	// Optimize for the case where our io.Writer is already a bytes.Buffer,
 	// but we always want to write into a Buffer first in case we have a slow
 	// io.Writer.
 	bb, isBuffer := w.(*bytes.Buffer)
 	if !isBuffer {
-		// if it's not, allocate a new one with a reasonable default
-		bb = bytes.NewBuffer(make([]byte, 0, minimumWriteBuffer))
+		// if it's not, take a pooled one sized by the previous output
+		bb = getScrapeBuffer(s.writeSizeHint())
+		defer putScrapeBuffer(bb)
 	} else {
-		bb.Grow(minimumWriteBuffer)
+		bb.Grow(s.writeSizeHint())
 	}
+	start := bb.Len()
 
 	exp := ExpfmtWriter{
 		b:            bb,
 		constantTags: s.constantTags,
 	}
 
-	s.collectInternal(exp, throttle)
+	s.collectInternal(ctx, exp, throttle)
+	s.lastWriteSize.Store(int64(bb.Len() - start))
 
 	if bb.Len() == 0 {
 		return 0, nil
@@ -315,9 +355,10 @@ func (s *Set) rangeChildrenSets(f func(s *Set) bool) {
 }
 
 // collectInternal is the unified collection logic used by both Collect and WritePrometheus.
-// It writes metrics, child sets, and collectors to the provided ExpfmtWriter.
+// It writes metrics, child sets, and collectors to the provided ExpfmtWriter,
+// and returns the errors of all collectors joined.
 // If throttle is true, it yields the scheduler periodically to avoid CPU starvation.
-func (s *Set) collectInternal(w ExpfmtWriter, throttle bool) {
+func (s *Set) collectInternal(ctx context.Context, w ExpfmtWriter, throttle bool) error {
 	// Write all metrics in this set
 	for _, nm := range s.metrics.Values() {
 		// yield the scheduler for each metric to not starve CPU
@@ -328,18 +369,11 @@ func (s *Set) collectInternal(w ExpfmtWriter, throttle bool) {
 	}
 
 	// Write all children sets recursively
-	s.collectChildrenSets(w, throttle)
+	childrenErr := s.collectChildrenSets(ctx, w, throttle)
 
 	// Collect from any registered collectors
-	if collectors := s.collectors.Load(); collectors != nil {
-		for _, c := range *collectors {
-			// yield the scheduler for each Collector to not starve CPU
-			if throttle {
-				runtime.Gosched()
-			}
-			c.Collect(w)
-		}
-	}
+	_, err := s.runCollectors(ctx, w, throttle, nil)
+	return errors.Join(childrenErr, err)
 }
 
 func (s *Set) isExpired() bool {
@@ -363,7 +397,7 @@ func (s *Set) mustStoreSet(set *Set) {
 			panic(fmt.Sprintf("metrics: set %v is already registered", set))
 		}
 	} else {
-		if _, loaded := s.setsByHash.LoadOrStore(set.id, set); loaded {
+		if _, loaded := s.storeSet(set.id, set); loaded {
 			panic(fmt.Sprintf("metrics: set %q is already registered", set.constantTags))
 		}
 	}
@@ -372,15 +406,38 @@ func (s *Set) mustStoreSet(set *Set) {
 // mustStoreMetric adds a new Metric, and will panic if the metric already has
 // been registered.
 func (s *Set) mustStoreMetric(m Metric, name MetricName) {
-	defer s.KeepAlive()
-	nm := &namedMetric{
-		id:     getHashTags(name.Family.String(), name.Tags),
+	s.mustStoreNamedMetric(&namedMetric{
 		name:   name,
 		metric: m,
+	})
+}
+
+// mustStoreNamedMetric is mustStoreMetric of a namedMetric which isn't
+// stored yet.
+func (s *Set) mustStoreNamedMetric(nm *namedMetric) {
+	defer s.KeepAlive()
+	if _, loaded := s.storeNamedMetric(getHashTags(nm.name.Family.String(), nm.name.Tags), nm); loaded {
+		panic(fmt.Sprintf("metrics: metric %q is already registered", nm.name.String()))
 	}
+}
 
-	if _, loaded := s.metrics.LoadOrStore(nm.id, nm); loaded {
-		panic(fmt.Sprintf("metrics: metric %q is already registered", name.String()))
+// loadMetricFromVec returns the metric for the hash of a Vec's values.
+// This is the lock-free fast path for Vecs, the stored name is verified so
+// a colliding hash can't return a different series.
+func (s *Set) loadMetricFromVec(
+	hash metricHash,
+	family Ident,
+	partialTags []Label,
+	values []string,
+) (*namedMetric, bool) {
+	for i := 0; ; i++ {
+		nm, ok := s.metrics.Load(probeHash(hash, i))
+		if !ok {
+			return nil, false
+		}
+		if nm.matchesValues(family, partialTags, values) {
+			return nm, true
+		}
 	}
 }
 
@@ -393,6 +450,18 @@ func (s *Set) loadOrStoreMetricFromVec(
 	family Ident,
 	partialTags []Label,
 	values []string,
+) *namedMetric {
+	return s.loadOrStoreNamedMetricFromVec(&namedMetric{metric: m}, hash, family, partialTags, values)
+}
+
+// loadOrStoreNamedMetricFromVec is loadOrStoreMetricFromVec of a namedMetric
+// which isn't stored yet, whose name is set from the Vec.
+func (s *Set) loadOrStoreNamedMetricFromVec(
+	newNm *namedMetric,
+	hash metricHash,
+	family Ident,
+	partialTags []Label,
+	values []string,
 ) *namedMetric {
 	if len(values) != len(partialTags) {
 		panic("metrics: mismatch length of labels and values")
@@ -405,14 +474,48 @@ func (s *Set) loadOrStoreMetricFromVec(
 			value: MustValue(values[i]),
 		}
 	}
-	return s.loadOrStoreNamedMetric(&namedMetric{
-		id: hash,
-		name: MetricName{
-			Family: family,
-			Tags:   tags,
-		},
-		metric: m,
-	})
+	newNm.name = MetricName{
+		Family: family,
+		Tags:   tags,
+	}
+	nm, _ := s.storeNamedMetric(hash, newNm)
+	return nm
+}
+
+// storeNamedMetric stores newNm, unless a metric with the same name is
+// already stored, which is returned with loaded true.
+//
+// Metrics are keyed by the hash of their name. If the hash collides with a
+// different series, the next key in the probe sequence is tried, so distinct
+// series never share a metric.
+func (s *Set) storeNamedMetric(hash metricHash, newNm *namedMetric) (nm *namedMetric, loaded bool) {
+	for i := 0; ; i++ {
+		newNm.id = probeHash(hash, i)
+		nm, loaded := s.metrics.LoadOrStore(newNm.id, newNm)
+		if !loaded {
+			if i > 0 {
+				hashCollisions.Add(1)
+			}
+			return nm, false
+		}
+		if nm.matchesName(newNm.name) {
+			return nm, true
+		}
+	}
+}
+
+// loadMetric returns the metric with the name's family and tags.
+func (s *Set) loadMetric(family string, tags []Tag) (*namedMetric, bool) {
+	hash := getHashTags(family, tags)
+	for i := 0; ; i++ {
+		nm, ok := s.metrics.Load(probeHash(hash, i))
+		if !ok {
+			return nil, false
+		}
+		if nm.name.Family.String() == family && tagsEqual(nm.name.Tags, tags) {
+			return nm, true
+		}
+	}
 }
 
 // loadOrStoreSetFromVec will attempt to create a new set or return one that
@@ -425,15 +528,16 @@ func (s *Set) loadOrStoreSetFromVec(
 	value string,
 ) *Set {
 	set := newSet()
-	set.id = hash
 	set.ttl = ttl
 	set.isActive = isActive
 	set.KeepAlive()
-	set.constantTags = joinTags(s.constantTags, Tag{
+	set.idTags = []Tag{{
 		label: label,
 		value: MustValue(value),
-	})
-	return s.loadOrStoreSet(set)
+	}}
+	set.joinConstantTags(s.constantTagList, set.idTags...)
+	set, _ = s.storeSet(hash, set)
+	return set
 }
 
 // KeepAlive is used to bump a Set's expiration when a TTL is set.
@@ -443,14 +547,47 @@ func (s *Set) KeepAlive() {
 	}
 }
 
-func (s *Set) loadOrStoreSet(newSet *Set) *Set {
-	set, _ := s.setsByHash.LoadOrStore(newSet.id, newSet)
-	return set
+// maxSetProbes bounds probing setsByHash past hash collisions. Unlike
+// metrics, children Sets are removed, so a lookup can't stop at the first
+// empty probe.
+const maxSetProbes = 8
+
+// loadSet returns the child Set identified by tags, which hash to hash.
+func (s *Set) loadSet(hash metricHash, tags ...Tag) (*Set, bool) {
+	for i := range maxSetProbes {
+		if set, ok := s.setsByHash.Load(probeHash(hash, i)); ok && tagsEqual(set.idTags, tags) {
+			return set, true
+		}
+	}
+	return nil, false
 }
 
-func (s *Set) loadOrStoreNamedMetric(newNm *namedMetric) *namedMetric {
-	nm, _ := s.metrics.LoadOrStore(newNm.id, newNm)
-	return nm
+// storeSet stores newSet at the first free probe of hash, unless a Set with
+// the same idTags is already stored, which is returned instead.
+func (s *Set) storeSet(hash metricHash, newSet *Set) (set *Set, loaded bool) {
+	if set, ok := s.loadSet(hash, newSet.idTags...); ok {
+		return set, true
+	}
+	for i := range maxSetProbes {
+		newSet.id = probeHash(hash, i)
+		set, loaded := s.setsByHash.LoadOrStore(newSet.id, newSet)
+		if !loaded {
+			if i > 0 {
+				hashCollisions.Add(1)
+			}
+			return set, false
+		}
+		if tagsEqual(set.idTags, newSet.idTags) {
+			return set, true
+		}
+	}
+	panic(fmt.Sprintf("metrics: too many hash collisions for set %q", newSet.constantTags))
+}
+
+// joinConstantTags sets the constant tags of s to previous followed by new.
+func (s *Set) joinConstantTags(previous []Tag, new ...Tag) {
+	s.constantTags = joinTags(materializeTags(previous), new...)
+	s.constantTagList = slices.Concat(previous, new)
 }
 
 func joinTags(previous string, new ...Tag) string {
@@ -487,6 +624,13 @@ func makeValues(values []string) []Value {
 // Collect implements the Collector interface, allowing a Set to be used as a Collector.
 // It writes all metrics in the Set and its children to the provided ExpfmtWriter.
 func (s *Set) Collect(w ExpfmtWriter) {
+	s.CollectContext(context.Background(), w)
+}
+
+// CollectContext implements the [ContextCollector] interface, like
+// [Set.Collect], passing ctx to the Collectors of the Set and its children.
+// The errors of all Collectors are returned joined.
+func (s *Set) CollectContext(ctx context.Context, w ExpfmtWriter) error {
 	// Append this Set's constant tags to the writer's existing tags
 	constantTags := w.ConstantTags()
 	if s.constantTags != "" {
@@ -501,12 +645,14 @@ func (s *Set) Collect(w ExpfmtWriter) {
 		constantTags: constantTags,
 	}
 
-	s.collectInternal(exp, false)
+	return s.collectInternal(ctx, exp, false)
 }
 
 // collectChildrenSets writes all child sets using the provided ExpfmtWriter,
-// preserving any existing constant tags in the writer.
-func (s *Set) collectChildrenSets(w ExpfmtWriter, throttle bool) {
+// preserving any existing constant tags in the writer, and returns the
+// errors of their collectors joined.
+func (s *Set) collectChildrenSets(ctx context.Context, w ExpfmtWriter, throttle bool) error {
+	var errs []error
 	s.rangeChildrenSets(func(child *Set) bool {
 		// Create a new writer with the child's tags appended to the current writer's tags
 		childWriter := ExpfmtWriter{
@@ -514,10 +660,13 @@ func (s *Set) collectChildrenSets(w ExpfmtWriter, throttle bool) {
 			constantTags: child.constantTags,
 		}
 
-		child.collectInternal(childWriter, throttle)
+		if err := child.collectInternal(ctx, childWriter, throttle); err != nil {
+			errs = append(errs, err)
+		}
 
 		return true
 	})
+	return errors.Join(errs...)
 }
 
 // GetMetricUint64 returns the current value of a [Uint64] metric by family name.
@@ -526,8 +675,7 @@ func (s *Set) collectChildrenSets(w ExpfmtWriter, throttle bool) {
 //
 // This is typically used within an [IsActiveFunc] to check metric values.
 func (s *Set) GetMetricUint64(family string) (uint64, bool) {
-	hash := getHashStrings(family, nil)
-	if nm, ok := s.metrics.Load(hash); ok {
+	if nm, ok := s.loadMetric(family, nil); ok {
 		if m, ok := nm.metric.(*Uint64); ok {
 			return m.Get(), true
 		}
@@ -541,8 +689,7 @@ func (s *Set) GetMetricUint64(family string) (uint64, bool) {
 //
 // This is typically used within an [IsActiveFunc] to check metric values.
 func (s *Set) GetMetricInt64(family string) (int64, bool) {
-	hash := getHashStrings(family, nil)
-	if nm, ok := s.metrics.Load(hash); ok {
+	if nm, ok := s.loadMetric(family, nil); ok {
 		if m, ok := nm.metric.(*Int64); ok {
 			return m.Get(), true
 		}
@@ -556,8 +703,7 @@ func (s *Set) GetMetricInt64(family string) (int64, bool) {
 //
 // This is typically used within an [IsActiveFunc] to check metric values.
 func (s *Set) GetMetricFloat64(family string) (float64, bool) {
-	hash := getHashStrings(family, nil)
-	if nm, ok := s.metrics.Load(hash); ok {
+	if nm, ok := s.loadMetric(family, nil); ok {
 		if m, ok := nm.metric.(*Float64); ok {
 			return m.Get(), true
 		}
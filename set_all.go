@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"iter"
+	"slices"
+)
+
+// MetricKind is the kind of value a [Metric] holds.
+type MetricKind uint8
+
+const (
+	// KindUnknown is a Metric of an unknown kind.
+	KindUnknown MetricKind = iota
+	// KindUint64 is a [Uint64] or [Uint64Func].
+	KindUint64
+	// KindInt64 is an [Int64] or [Int64Func].
+	KindInt64
+	// KindFloat64 is a [Float64] or [Float64Func].
+	KindFloat64
+	// KindHistogram is a [Histogram] with `vmrange` buckets.
+	KindHistogram
+	// KindFixedHistogram is a Prometheus-like histogram with `le` buckets,
+	// such as a [FixedHistogram], [DecayingHistogram], [WindowedHistogram]
+	// or a view from [Set.NewFixedHistogramView].
+	KindFixedHistogram
+	// KindSketch is a [Sketch], written as a summary of quantiles.
+	KindSketch
+)
+
+func (k MetricKind) String() string {
+	switch k {
+	case KindUint64:
+		return "uint64"
+	case KindInt64:
+		return "int64"
+	case KindFloat64:
+		return "float64"
+	case KindHistogram:
+		return "histogram"
+	case KindFixedHistogram:
+		return "fixed_histogram"
+	case KindSketch:
+		return "sketch"
+	default:
+		return "unknown"
+	}
+}
+
+// MetricKindOf returns the kind of m.
+func MetricKindOf(m Metric) MetricKind {
+	switch m.(type) {
+	case *Uint64, *Uint64Func:
+		return KindUint64
+	case *Int64, *Int64Func:
+		return KindInt64
+	case *Float64, *Float64Func:
+		return KindFloat64
+	case *Histogram:
+		return KindHistogram
+	case *FixedHistogram, *DecayingHistogram, *WindowedHistogram, *fixedHistogramView:
+		return KindFixedHistogram
+	case *Sketch:
+		return KindSketch
+	default:
+		return KindUnknown
+	}
+}
+
+// All returns an iterator over all metrics in the Set along with all
+// children Sets, in the same order they are written by
+// [Set.WritePrometheus]. Output of Collectors is not included.
+//
+// Each MetricName has the constant tags of its Set resolved, followed by
+// the tags of the metric, so it is the name the metric is written with.
+// Expired children Sets are skipped.
+//
+// The Tags of a yielded MetricName must not be modified.
+func (s *Set) All() iter.Seq2[MetricName, Metric] {
+	return func(yield func(MetricName, Metric) bool) {
+		s.all(yield)
+	}
+}
+
+func (s *Set) all(yield func(MetricName, Metric) bool) bool {
+	for _, nm := range s.metrics.Values() {
+		if !yield(s.resolveName(nm.name), nm.metric) {
+			return false
+		}
+	}
+	keepGoing := true
+	s.rangeChildrenSets(func(child *Set) bool {
+		keepGoing = child.all(yield)
+		return keepGoing
+	})
+	return keepGoing
+}
+
+// resolveName returns name with the constant tags of s prepended.
+func (s *Set) resolveName(name MetricName) MetricName {
+	if len(s.constantTagList) == 0 {
+		return MetricName{
+			Family: name.Family,
+			Tags:   slices.Clip(name.Tags),
+		}
+	}
+	return MetricName{
+		Family: name.Family,
+		Tags:   slices.Concat(s.constantTagList, name.Tags),
+	}
+}
+
+// Lookup returns the Metric with the name in the Set or any of its children
+// Sets. The name's Tags must include the constant tags of the Set the metric
+// is in, as yielded by [Set.All].
+//
+// Use a type assertion or [MetricKindOf] on the returned Metric to read its
+// value, for instance:
+//
+//	if m, ok := set.Lookup(metrics.NewMetricName("requests_total", "code", "200")); ok {
+//		count := m.(*metrics.Uint64).Get()
+//	}
+func (s *Set) Lookup(name MetricName) (Metric, bool) {
+	if tags, ok := cutTagsPrefix(name.Tags, s.constantTagList); ok {
+		if nm, ok := s.loadMetric(name.Family.String(), tags); ok {
+			return nm.metric, true
+		}
+	}
+
+	var (
+		m     Metric
+		found bool
+	)
+	s.rangeChildrenSets(func(child *Set) bool {
+		m, found = child.Lookup(name)
+		return !found
+	})
+	return m, found
+}
+
+// cutTagsPrefix returns tags without the leading prefix, and reports if
+// tags started with prefix.
+func cutTagsPrefix(tags, prefix []Tag) ([]Tag, bool) {
+	if len(tags) < len(prefix) || !tagsEqual(tags[:len(prefix)], prefix) {
+		return nil, false
+	}
+	return tags[len(prefix):], true
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"slices"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestSetAll(t *testing.T) {
+	set := NewSet("env", "prod")
+	set.NewCounter("requests_total", "code", "200").Add(3)
+	set.NewFloat64("temperature").Set(1.5)
+	set.NewHistogram("latency")
+
+	sv := set.NewSetVec("shard")
+	sv.NewInt64("in_flight", "a").Set(2)
+
+	child := set.NewSet("pool", "x")
+	child.NewFixedHistogram("size", nil)
+	child.NewSketch("duration", 0, nil)
+
+	var names []string
+	kinds := make(map[string]MetricKind)
+	for name, m := range set.All() {
+		names = append(names, name.String())
+		kinds[name.Family.String()] = MetricKindOf(m)
+	}
+	// children Sets are unordered
+	slices.Sort(names[3:])
+	assert.SlicesEqual(t, names, []string{
+		`latency{env="prod"}`,
+		`requests_total{env="prod",code="200"}`,
+		`temperature{env="prod"}`,
+		`duration{env="prod",pool="x"}`,
+		`in_flight{env="prod",shard="a"}`,
+		`size{env="prod",pool="x"}`,
+	})
+	assert.Equal(t, kinds["latency"], KindHistogram)
+	assert.Equal(t, kinds["requests_total"], KindUint64)
+	assert.Equal(t, kinds["temperature"], KindFloat64)
+	assert.Equal(t, kinds["duration"], KindSketch)
+	assert.Equal(t, kinds["in_flight"], KindInt64)
+	assert.Equal(t, kinds["size"], KindFixedHistogram)
+
+	// stopping early
+	var n int
+	for range set.All() {
+		n++
+		if n == 4 {
+			break
+		}
+	}
+	assert.Equal(t, n, 4)
+
+	// every yielded name can be looked up
+	for name, m := range set.All() {
+		got, ok := set.Lookup(name)
+		assert.True(t, ok)
+		assert.True(t, got == m)
+	}
+
+	m, ok := set.Lookup(NewMetricName("requests_total", "env", "prod", "code", "200"))
+	assert.True(t, ok)
+	assert.Equal(t, m.(*Uint64).Get(), 3)
+
+	m, ok = set.Lookup(NewMetricName("in_flight", "env", "prod", "shard", "a"))
+	assert.True(t, ok)
+	assert.Equal(t, m.(*Int64).Get(), 2)
+
+	for _, name := range []MetricName{
+		NewMetricName("requests_total", "code", "200"),
+		NewMetricName("requests_total", "env", "prod"),
+		NewMetricName("requests_total", "env", "prod", "code", "500"),
+		NewMetricName("in_flight", "env", "prod", "shard", "b"),
+		NewMetricName("missing"),
+	} {
+		_, ok := set.Lookup(name)
+		assert.False(t, ok)
+	}
+}
+
+func TestMetricKind(t *testing.T) {
+	assert.Equal(t, KindUint64.String(), "uint64")
+	assert.Equal(t, KindFixedHistogram.String(), "fixed_histogram")
+	assert.Equal(t, MetricKindOf(nil).String(), "unknown")
+	assert.Equal(t, MetricKindOf(&Float64Func{}), KindFloat64)
+}
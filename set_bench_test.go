@@ -2,7 +2,9 @@ package metrics
 
 import (
 	"bytes"
+	"io"
 	"math"
+	"strconv"
 	"testing"
 )
 
@@ -50,3 +52,17 @@ func BenchmarkWritePrometheus(b *testing.B) {
 		b.SetBytes(int64(bb.Len()))
 	}
 }
+
+func BenchmarkWritePrometheusStreaming(b *testing.B) {
+	set := NewSet()
+	for i := range 10000 {
+		set.NewCounter("requests_total", "id", strconv.Itoa(i)).Inc()
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		n, _ := set.WritePrometheusStreaming(io.Discard)
+		b.SetBytes(int64(n))
+	}
+}
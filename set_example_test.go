@@ -3,6 +3,7 @@ package metrics_test
 import (
 	"bytes"
 	"fmt"
+	"os"
 
 	"go.withmatt.com/metrics"
 )
@@ -32,3 +33,83 @@ func ExampleWritePrometheus() {
 	// Output:
 	// foo{label1="value1"} 1
 }
+
+func ExampleSet_WritePrometheusSorted() {
+	set := metrics.NewSet()
+	sv := set.NewSetVec("shard")
+	for _, shard := range []string{"b", "a"} {
+		sv.NewCounter("requests_total", shard).Inc()
+		sv.NewInt64("in_flight", shard).Set(2)
+	}
+
+	// Families are written together across all of the children Sets.
+	var b bytes.Buffer
+	set.WritePrometheusSorted(&b)
+	fmt.Print(b.String())
+
+	// Output:
+	// in_flight{shard="a"} 2
+	// in_flight{shard="b"} 2
+	// requests_total{shard="a"} 1
+	// requests_total{shard="b"} 1
+}
+
+func ExampleSet_All() {
+	set := metrics.NewSet("env", "prod")
+	set.NewCounter("requests_total", "code", "200").Add(3)
+	set.NewFloat64("temperature").Set(21.5)
+
+	for name, m := range set.All() {
+		fmt.Println(name, metrics.MetricKindOf(m))
+	}
+
+	// Output:
+	// requests_total{env="prod",code="200"} uint64
+	// temperature{env="prod"} float64
+}
+
+func ExampleSet_Lookup() {
+	set := metrics.NewSet()
+	set.NewCounter("requests_total", "code", "200").Add(3)
+
+	if m, ok := set.Lookup(metrics.NewMetricName("requests_total", "code", "200")); ok {
+		fmt.Println(m.(*metrics.Uint64).Get())
+	}
+
+	// Output:
+	// 3
+}
+
+func ExampleSet_Gather() {
+	set := metrics.NewSet("env", "prod")
+	set.NewCounter("requests_total", "code", "200").Add(3)
+	set.NewFixedHistogram("size_bytes", []float64{1, 10}).Update(5)
+
+	families, err := set.Gather()
+	if err != nil {
+		panic(err)
+	}
+	for _, f := range families {
+		for _, s := range f.Samples {
+			fmt.Println(f.Name, f.Type, f.Unit, s.Tags, s.Value, s.Count, s.Buckets)
+		}
+	}
+
+	// Output:
+	// requests_total counter  [env="prod" code="200"] 3 0 []
+	// size_bytes histogram bytes [env="prod"] 0 1 [{1 0} {10 1} {+Inf 1}]
+}
+
+func ExampleSet_WritePrometheusStreaming() {
+	set := metrics.NewSet()
+	set.NewCounter("foo").Inc()
+
+	// Output is flushed to the io.Writer in chunks, and writing stops at
+	// the first error from it.
+	if _, err := set.WritePrometheusStreaming(os.Stdout); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// foo 1
+}
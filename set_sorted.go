@@ -0,0 +1,300 @@
+package metrics
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// WritePrometheusSorted writes the global Set to io.Writer with families
+// merged across children Sets.
+// See [Set.WritePrometheusSorted].
+func WritePrometheusSorted(w io.Writer) (int, error) {
+	return defaultSet.WritePrometheusSorted(w)
+}
+
+// WritePrometheusSortedContext writes the global Set to io.Writer with
+// families merged across children Sets.
+// See [Set.WritePrometheusSortedContext].
+func WritePrometheusSortedContext(ctx context.Context, w io.Writer) (int, error) {
+	return defaultSet.WritePrometheusSortedContext(ctx, w)
+}
+
+// WritePrometheusSorted writes the metrics along with all children to the
+// io.Writer in Prometheus text exposition format, like
+// [Set.WritePrometheus], but with all series of a family written together,
+// ordered by their tags, across all children Sets and Collectors.
+//
+// [Set.WritePrometheus] writes each Set in turn, so a family registered in
+// many children Sets, such as through a [SetVec], is split into
+// non-contiguous groups. The exposition formats require a family to be
+// contiguous, which this guarantees at the cost of gathering and sorting the
+// series of the whole tree first.
+//
+// Collector output is split into series by family and tags. The _bucket, _sum
+// and _count lines of histograms and summaries are kept together, and sorted
+// by their tags without the `le`, `vmrange` and `quantile` labels.
+//
+// Metric writing and collecting is throttled by yielding the Go scheduler to
+// not starve CPU.
+func (s *Set) WritePrometheusSorted(w io.Writer) (int, error) {
+	return s.WritePrometheusSortedContext(context.Background(), w)
+}
+
+// WritePrometheusSortedContext writes the metrics along with all children to
+// the io.Writer with families merged across children Sets, like
+// [Set.WritePrometheusSorted].
+//
+// ctx is passed to each [ContextCollector], and its deadline is the deadline
+// for Collectors run with [WithParallelCollectors].
+func (s *Set) WritePrometheusSortedContext(ctx context.Context, w io.Writer) (int, error) {
+	if s.isExpired() {
+		return 0, ErrSetExpired
+	}
+
+	bb, isBuffer := w.(*bytes.Buffer)
+	if !isBuffer {
+		bb = getScrapeBuffer(s.writeSizeHint())
+		defer putScrapeBuffer(bb)
+	} else {
+		bb.Grow(s.writeSizeHint())
+	}
+	start := bb.Len()
+
+	var g seriesGatherer
+	g.gather(ctx, s, s.constantTags, true)
+	g.sort()
+	g.writeTo(bb, true)
+	s.lastWriteSize.Store(int64(bb.Len() - start))
+
+	if bb.Len() == 0 {
+		return 0, nil
+	}
+	if !isBuffer {
+		return w.Write(bb.Bytes())
+	}
+	return bb.Len(), nil
+}
+
+// sortedSeries is either a single metric of a Set, or the lines of a single
+// series written by a Collector.
+type sortedSeries struct {
+	family string
+	// constantTags are the tags the metric is written with, or all tags of
+	// Collector output, see seriesTags.
+	constantTags string
+	nm           *namedMetric
+	// start and end are the Collector output within seriesGatherer.collected
+	start, end int
+}
+
+func compareSortedSeries(a, b sortedSeries) int {
+	if c := cmp.Compare(a.family, b.family); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.constantTags, b.constantTags); c != 0 {
+		return c
+	}
+	var aTags, bTags []Tag
+	if a.nm != nil {
+		aTags = a.nm.name.Tags
+	}
+	if b.nm != nil {
+		bTags = b.nm.name.Tags
+	}
+	return compareTags(aTags, bTags)
+}
+
+// seriesGatherer gathers the series of a Set tree to be sorted.
+type seriesGatherer struct {
+	series []sortedSeries
+	// collected is the output of all Collectors
+	collected bytes.Buffer
+}
+
+func (g *seriesGatherer) gather(ctx context.Context, s *Set, constantTags string, throttle bool) {
+	for _, nm := range s.metrics.Values() {
+		g.series = append(g.series, sortedSeries{
+			family:       nm.name.Family.String(),
+			constantTags: constantTags,
+			nm:           nm,
+		})
+	}
+
+	s.rangeChildrenSets(func(child *Set) bool {
+		g.gather(ctx, child, child.constantTags, throttle)
+		return true
+	})
+
+	w := ExpfmtWriter{
+		b:            &g.collected,
+		constantTags: constantTags,
+	}
+	s.runCollectors(ctx, w, throttle, func(start int) bool {
+		g.splitCollected(start)
+		return true
+	})
+}
+
+// splitCollected splits Collector output written from start into series.
+func (g *seriesGatherer) splitCollected(start int) {
+	data := g.collected.Bytes()
+	cur := -1
+	// comments are kept with the following line
+	commentStart := -1
+
+	for start < len(data) {
+		end := len(data)
+		if i := bytes.IndexByte(data[start:], '\n'); i >= 0 {
+			end = start + i + 1
+		}
+		line := data[start:end]
+		lineStart := start
+		start = end
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if line[0] == '#' {
+			if commentStart < 0 {
+				commentStart = lineStart
+			}
+			continue
+		}
+
+		name, tags := splitSeriesLine(line)
+		tags, bucket := seriesTags(tags)
+		if cur >= 0 && commentStart < 0 &&
+			continuesFamily(g.series[cur].family, name, bucket) &&
+			g.series[cur].constantTags == tags {
+			g.series[cur].end = end
+			continue
+		}
+		if commentStart >= 0 {
+			lineStart = commentStart
+			commentStart = -1
+		}
+		g.series = append(g.series, sortedSeries{
+			family:       baseFamily(name, bucket),
+			constantTags: tags,
+			start:        lineStart,
+			end:          end,
+		})
+		cur = len(g.series) - 1
+	}
+}
+
+// splitSeriesLine returns the metric name and the tags of a line.
+func splitSeriesLine(line []byte) (name, tags string) {
+	i := bytes.IndexAny(line, "{ ")
+	if i < 0 {
+		return string(bytes.TrimSpace(line)), ""
+	}
+	name = string(line[:i])
+	if line[i] == '{' {
+		if j := bytes.LastIndexByte(line, '}'); j > i {
+			tags = string(line[i+1 : j])
+		}
+	}
+	return name, tags
+}
+
+// seriesTags returns tags without the labels which identify a line within a
+// histogram or summary series, and reports if the line is a histogram bucket
+// by having an `le` or `vmrange` label.
+func seriesTags(tags string) (series string, bucket bool) {
+	if !strings.Contains(tags, `le="`) &&
+		!strings.Contains(tags, `vmrange="`) &&
+		!strings.Contains(tags, `quantile="`) {
+		return tags, false
+	}
+
+	var b strings.Builder
+	for rest := tags; rest != ""; {
+		label, pair, next, ok := cutTagPair(rest)
+		if !ok {
+			return tags, false
+		}
+		switch label {
+		case "le", "vmrange":
+			bucket = true
+		case "quantile":
+		default:
+			if b.Len() > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(pair)
+		}
+		rest = next
+	}
+	return b.String(), bucket
+}
+
+// cutTagPair cuts the first label="value" pair from tags.
+func cutTagPair(tags string) (label, pair, rest string, ok bool) {
+	label, value, ok := strings.Cut(tags, `="`)
+	if !ok {
+		return "", "", "", false
+	}
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			pair = tags[:len(label)+len(`="`)+i+1]
+			return label, pair, strings.TrimPrefix(value[i+1:], ","), true
+		}
+	}
+	return "", "", "", false
+}
+
+var familySuffixes = [...]string{"_bucket", "_sum", "_count"}
+
+// baseFamily returns the family of a histogram's _bucket line, or name.
+// Only lines of buckets, with an `le` or `vmrange` label, are stripped of
+// _bucket, so a family such as s3_bucket is kept.
+func baseFamily(name string, bucket bool) string {
+	if base, ok := strings.CutSuffix(name, "_bucket"); ok && bucket {
+		return base
+	}
+	return name
+}
+
+// continuesFamily reports if a line of name continues a run of family, such
+// as the _sum and _count of a histogram or summary.
+func continuesFamily(family, name string, bucket bool) bool {
+	rest, ok := strings.CutPrefix(name, family)
+	if !ok {
+		return false
+	}
+	if rest == "_bucket" {
+		return bucket
+	}
+	return rest == "" || slices.Contains(familySuffixes[:], rest)
+}
+
+func (g *seriesGatherer) sort() {
+	slices.SortStableFunc(g.series, compareSortedSeries)
+}
+
+func (g *seriesGatherer) writeTo(bb *bytes.Buffer, throttle bool) {
+	collected := g.collected.Bytes()
+	for _, ss := range g.series {
+		if ss.nm == nil {
+			bb.Write(collected[ss.start:ss.end])
+			continue
+		}
+		// yield the scheduler for each metric to not starve CPU
+		if throttle {
+			runtime.Gosched()
+		}
+		ss.nm.metric.marshalTo(ExpfmtWriter{
+			b:            bb,
+			constantTags: ss.constantTags,
+		}, ss.nm.name)
+	}
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func assertMarshalSorted(tb testing.TB, set *Set, expected []string) {
+	tb.Helper()
+	var b bytes.Buffer
+	set.WritePrometheusSorted(&b)
+	out := strings.Trim(b.String(), "\n")
+	lines := splitLines(out)
+	expected = splitLines(strings.Join(expected, "\n"))
+	assert.LinesEqual(tb, lines, expected)
+}
+
+func TestWritePrometheusSorted(t *testing.T) {
+	set := NewSet("app", "test")
+	set.NewUint64("requests_total", "code", "500").Add(1)
+	set.NewUint64("requests_total", "code", "200").Add(2)
+	set.NewInt64("active").Set(3)
+
+	sv := set.NewSetVec("shard")
+	for _, shard := range []string{"b", "a", "c"} {
+		s := sv.WithLabelValue(shard)
+		s.NewUint64("requests_total", "code", "200").Add(4)
+		s.NewInt64("active").Set(5)
+	}
+
+	set.RegisterCollector(
+		CollectorFunc(func(w ExpfmtWriter) {
+			w.WriteLazyMetricUint64("collected", 1, "x", "b")
+			w.WriteLazyMetricUint64("collected", 2, "x", "a")
+			w.WriteLazyMetricUint64("requests_total", 6, "code", "300")
+		}),
+		CollectorFunc(func(w ExpfmtWriter) {
+			w.WriteLazyMetricUint64("collected", 3, "x", "0")
+		}),
+	)
+
+	assertMarshalSorted(t, set, []string{
+		`active{app="test"} 3`,
+		`active{app="test",shard="a"} 5`,
+		`active{app="test",shard="b"} 5`,
+		`active{app="test",shard="c"} 5`,
+		`collected{app="test",x="0"} 3`,
+		`collected{app="test",x="a"} 2`,
+		`collected{app="test",x="b"} 1`,
+		`requests_total{app="test",code="200"} 2`,
+		`requests_total{app="test",code="500"} 1`,
+		`requests_total{app="test",code="300"} 6`,
+		`requests_total{app="test",shard="a",code="200"} 4`,
+		`requests_total{app="test",shard="b",code="200"} 4`,
+		`requests_total{app="test",shard="c",code="200"} 4`,
+	})
+}
+
+func TestWritePrometheusSortedHistograms(t *testing.T) {
+	set := NewSet()
+	sv := set.NewSetVecWithTTL("shard", 0)
+	sv.WithLabelValue("b").NewFixedHistogram("latency", []float64{1}).Update(0.5)
+	set.NewUint64("latency_extra").Inc()
+	sv.WithLabelValue("a").NewFixedHistogram("latency", []float64{1}).Update(2)
+
+	set.RegisterCollector(CollectorFunc(func(w ExpfmtWriter) {
+		for _, host := range []string{"b", "a"} {
+			w.WriteLazyMetricUint64("summary", 2, "host", host, "quantile", "0.5")
+			w.WriteLazyMetricUint64("summary_sum", 2, "host", host)
+			w.WriteLazyMetricUint64("summary_count", 2, "host", host)
+		}
+		w.WriteLazyMetricUint64("summary", 1, "quantile", "0.5")
+		w.WriteLazyMetricUint64("summary_sum", 1)
+		w.WriteLazyMetricUint64("summary_count", 1)
+		w.WriteLine([]byte("# a comment\n"))
+		w.WriteLazyMetricUint64("after", 1)
+	}))
+
+	assertMarshalSorted(t, set, []string{
+		`# a comment`,
+		`after 1`,
+		`latency_bucket{le="1",shard="a"} 0`,
+		`latency_bucket{le="+Inf",shard="a"} 1`,
+		`latency_sum{shard="a"} 2`,
+		`latency_count{shard="a"} 1`,
+		`latency_bucket{le="1",shard="b"} 1`,
+		`latency_bucket{le="+Inf",shard="b"} 1`,
+		`latency_sum{shard="b"} 0.5`,
+		`latency_count{shard="b"} 1`,
+		`latency_extra 1`,
+		`summary{quantile="0.5"} 1`,
+		`summary_sum 1`,
+		`summary_count 1`,
+		`summary{host="a",quantile="0.5"} 2`,
+		`summary_sum{host="a"} 2`,
+		`summary_count{host="a"} 2`,
+		`summary{host="b",quantile="0.5"} 2`,
+		`summary_sum{host="b"} 2`,
+		`summary_count{host="b"} 2`,
+	})
+}
+
+func TestWritePrometheusSortedBucketFamily(t *testing.T) {
+	set := NewSet()
+	set.NewUint64("s3_bucket", "name", "b").Inc()
+	set.NewUint64("s3").Inc()
+	set.NewUint64("s4").Inc()
+	set.RegisterCollector(CollectorFunc(func(w ExpfmtWriter) {
+		// only lines with an `le` or `vmrange` label are histogram buckets
+		w.WriteLazyMetricUint64("s3_bucket", 2, "name", "a")
+		w.WriteLazyMetricUint64("s3_bucket", 3, "name", "c")
+	}))
+
+	assertMarshalSorted(t, set, []string{
+		`s3 1`,
+		`s3_bucket{name="b"} 1`,
+		`s3_bucket{name="a"} 2`,
+		`s3_bucket{name="c"} 3`,
+		`s4 1`,
+	})
+}
+
+func TestWritePrometheusSortedExpired(t *testing.T) {
+	set := NewSet()
+	set.ttl = 1
+	set.lastUsed.Store(fastClock().Now() - 10)
+	_, err := set.WritePrometheusSorted(&bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrSetExpired)
+}
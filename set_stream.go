@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+)
+
+// streamChunkSize is the size buffered before being flushed to the
+// io.Writer when streaming.
+const streamChunkSize = minimumWriteBuffer
+
+// WritePrometheusStreaming writes the global Set to io.Writer in chunks.
+// See [Set.WritePrometheusStreaming].
+func WritePrometheusStreaming(w io.Writer) (int, error) {
+	return defaultSet.WritePrometheusStreaming(w)
+}
+
+// WritePrometheusStreamingContext writes the global Set to io.Writer in
+// chunks.
+// See [Set.WritePrometheusStreamingContext].
+func WritePrometheusStreamingContext(ctx context.Context, w io.Writer) (int, error) {
+	return defaultSet.WritePrometheusStreamingContext(ctx, w)
+}
+
+// WritePrometheusStreaming writes the metrics along with all children to the
+// io.Writer in Prometheus text exposition format, like [Set.WritePrometheus],
+// but without first buffering the whole output.
+//
+// Output is buffered into a small pooled buffer which is flushed to w
+// whenever it fills up, between metrics and Collectors, so memory use is
+// bounded by the largest single metric or Collector rather than the whole
+// Set. This is preferred for Sets with very many series, especially with
+// concurrent scrapes.
+//
+// Writing stops at the first error from w, which is returned along with the
+// number of bytes written so far. The output written up to that point ends
+// on a complete line.
+//
+// Metric writing and collecting is throttled by yielding the Go scheduler to
+// not starve CPU.
+func (s *Set) WritePrometheusStreaming(w io.Writer) (int, error) {
+	return s.WritePrometheusStreamingContext(context.Background(), w)
+}
+
+// WritePrometheusStreamingContext writes the metrics along with all children
+// to the io.Writer in chunks, like [Set.WritePrometheusStreaming].
+//
+// ctx is passed to each [ContextCollector], and its deadline is the deadline
+// for Collectors run with [WithParallelCollectors].
+func (s *Set) WritePrometheusStreamingContext(ctx context.Context, w io.Writer) (int, error) {
+	if s.isExpired() {
+		return 0, ErrSetExpired
+	}
+
+	bb := getScrapeBuffer(streamChunkSize)
+	defer putScrapeBuffer(bb)
+
+	sw := streamWriter{w: w, bb: bb}
+	if s.stream(ctx, &sw, s.constantTags) {
+		sw.flush()
+	}
+	return sw.n, sw.err
+}
+
+// streamWriter flushes a buffer to an io.Writer in chunks, and keeps the
+// first error from the io.Writer.
+type streamWriter struct {
+	w   io.Writer
+	bb  *bytes.Buffer
+	n   int
+	err error
+}
+
+// flush writes out the buffer, and reports if writing can continue.
+func (sw *streamWriter) flush() bool {
+	if sw.err != nil {
+		return false
+	}
+	if size := sw.bb.Len(); size > 0 {
+		n, err := sw.w.Write(sw.bb.Bytes())
+		sw.n += n
+		sw.bb.Reset()
+		if err == nil && n < size {
+			err = io.ErrShortWrite
+		}
+		sw.err = err
+	}
+	return sw.err == nil
+}
+
+// maybeFlush flushes once the buffer is full, and reports if writing can
+// continue.
+func (sw *streamWriter) maybeFlush() bool {
+	if sw.bb.Len() < streamChunkSize {
+		return true
+	}
+	return sw.flush()
+}
+
+// stream mirrors collectInternal, but flushes as it goes, and stops once
+// the io.Writer fails.
+func (s *Set) stream(ctx context.Context, sw *streamWriter, constantTags string) bool {
+	w := ExpfmtWriter{
+		b:            sw.bb,
+		constantTags: constantTags,
+	}
+
+	for _, nm := range s.metrics.Values() {
+		// yield the scheduler for each metric to not starve CPU
+		runtime.Gosched()
+		nm.metric.marshalTo(w, nm.name)
+		if !sw.maybeFlush() {
+			return false
+		}
+	}
+
+	keepGoing := true
+	s.rangeChildrenSets(func(child *Set) bool {
+		keepGoing = child.stream(ctx, sw, child.constantTags)
+		return keepGoing
+	})
+	if !keepGoing {
+		return false
+	}
+
+	keepGoing, _ = s.runCollectors(ctx, w, true, func(int) bool {
+		return sw.maybeFlush()
+	})
+	return keepGoing
+}
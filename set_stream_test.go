@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+// chunkRecorder records each Write, and fails after failAfter writes.
+type chunkRecorder struct {
+	bytes.Buffer
+	writes    int
+	failAfter int
+}
+
+var errTestWrite = errors.New("write failed")
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	if c.failAfter > 0 && c.writes >= c.failAfter {
+		return 0, errTestWrite
+	}
+	c.writes++
+	return c.Buffer.Write(p)
+}
+
+func newStreamingTestSet(n int) *Set {
+	set := NewSet("env", "prod")
+	for i := range n {
+		set.NewCounter("requests_total", "path", fmt.Sprintf("/some/long/path/%06d", i)).Inc()
+	}
+	child := set.NewSet("shard", "a")
+	child.NewCounter("child_total").Add(2)
+	set.RegisterCollector(CollectorFunc(func(w ExpfmtWriter) {
+		w.WriteLazyMetricUint64("collected", 3)
+	}))
+	return set
+}
+
+func TestWritePrometheusStreaming(t *testing.T) {
+	set := newStreamingTestSet(2000)
+
+	var want bytes.Buffer
+	set.WritePrometheus(&want)
+
+	var got chunkRecorder
+	n, err := set.WritePrometheusStreaming(&got)
+	assert.Nil(t, err)
+	assert.Equal(t, n, want.Len())
+	assert.Equal(t, got.String(), want.String())
+	// output was flushed in multiple chunks
+	assert.Greater(t, got.writes, 1)
+
+	// small output is a single write
+	small := NewSet()
+	small.NewCounter("foo").Inc()
+	var one chunkRecorder
+	n, err = small.WritePrometheusStreaming(&one)
+	assert.Nil(t, err)
+	assert.Equal(t, n, len("foo 1\n"))
+	assert.Equal(t, one.writes, 1)
+
+	// empty output doesn't write
+	var none chunkRecorder
+	n, err = NewSet().WritePrometheusStreaming(&none)
+	assert.Nil(t, err)
+	assert.Equal(t, n, 0)
+	assert.Equal(t, none.writes, 0)
+}
+
+func TestWritePrometheusStreamingError(t *testing.T) {
+	set := newStreamingTestSet(2000)
+
+	got := chunkRecorder{failAfter: 1}
+	n, err := set.WritePrometheusStreaming(&got)
+	assert.ErrorIs(t, err, errTestWrite)
+	assert.Equal(t, n, got.Len())
+	assert.Equal(t, got.writes, 1)
+	// a partial output ends on a complete line
+	assert.True(t, strings.HasSuffix(got.String(), "\n"))
+
+	expired := NewSet().NewSetVecWithTTL("a", 1).WithLabelValue("x")
+	expired.lastUsed.Store(0)
+	_, err = expired.WritePrometheusStreaming(&got)
+	assert.ErrorIs(t, err, ErrSetExpired)
+}
@@ -57,7 +57,7 @@ func (s *Set) NewSetVecWithTTL(label string, ttl time.Duration) *SetVec {
 func (sv *SetVec) WithLabelValue(value string) *Set {
 	hash := hashFinish(sv.partialHash, value)
 
-	set, ok := sv.s.setsByHash.Load(hash)
+	set, ok := sv.s.loadSet(hash, Tag{label: sv.label, value: UnsafeValue(value)})
 	if !ok {
 		set = sv.s.loadOrStoreSetFromVec(hash, sv.ttl, sv.isActive, sv.label, value)
 	}
@@ -67,7 +67,10 @@ func (sv *SetVec) WithLabelValue(value string) *Set {
 
 // RemoveByLabelValue removes the Set for the corresponding label value.
 func (sv *SetVec) RemoveByLabelValue(value string) {
-	sv.s.setsByHash.Delete(hashFinish(sv.partialHash, value))
+	hash := hashFinish(sv.partialHash, value)
+	if set, ok := sv.s.loadSet(hash, Tag{label: sv.label, value: UnsafeValue(value)}); ok {
+		sv.s.setsByHash.CompareAndDelete(set.id, set)
+	}
 }
 
 // SetIsActive sets a callback to determine if [Set]s created by this [SetVec] should be kept alive.
@@ -89,7 +92,7 @@ func (sv *SetVec) SetIsActive(fn IsActiveFunc) {
 
 // NewUint64Vec creates a new [Uint64Vec] with the supplied name.
 func (sv *SetVec) NewUint64Vec(family string, labels ...string) *Uint64Vec {
-	return &Uint64Vec{getCommonVecSetVec(sv, family, labels)}
+	return &Uint64Vec{commonVec: getCommonVecSetVec(sv, family, labels)}
 }
 
 // NewUint64 registers and returns new Uint64 using the label from the SetVec.
@@ -0,0 +1,599 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefQuantiles is the default set of quantiles exposed by a [Sketch].
+var DefQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+const (
+	// defaultSketchAccuracy is the relative accuracy used for a Sketch
+	// when none is given.
+	defaultSketchAccuracy = 0.01
+
+	// sketchMaxBins is the maximum number of bins per sign a Sketch
+	// keeps before collapsing the lowest ones. With the default accuracy,
+	// this covers values spanning more than 17 orders of magnitude.
+	sketchMaxBins = 2048
+
+	sketchEncodingVersion = 1
+)
+
+var (
+	quantileLabel = MustLabel("quantile")
+
+	errSketchEncoding      = errors.New("metrics: invalid Sketch encoding")
+	errSketchEncodingShort = errors.New("metrics: short Sketch encoding")
+)
+
+// NewSketch creates a new Sketch on the global Set.
+// See [Set.NewSketch].
+func NewSketch(family string, relativeAccuracy float64, quantiles []float64, tags ...string) *Sketch {
+	return defaultSet.NewSketch(family, relativeAccuracy, quantiles, tags...)
+}
+
+// NewSketch creates and returns new Sketch in s with the given name.
+//
+// family must be a Prometheus compatible identifier format.
+//
+// relativeAccuracy must be between 0 and 1 exclusive, or 0 to use a
+// relative accuracy of 1%. If quantiles is empty, [DefQuantiles] are exposed.
+//
+// Optional tags must be specified in [label, value] pairs, for instance,
+//
+//	NewSketch("family", 0.01, []float64{0.5, 0.99}, "label1", "value1")
+//
+// The returned Sketch is safe to use from concurrent goroutines.
+//
+// This will panic if values are invalid or already registered.
+func (s *Set) NewSketch(family string, relativeAccuracy float64, quantiles []float64, tags ...string) *Sketch {
+	sk := newSketch(relativeAccuracy, quantiles)
+	s.mustStoreMetric(sk, MetricName{
+		Family: MustIdent(family),
+		Tags:   MustTags(tags...),
+	})
+	return sk
+}
+
+// Sketch is a mergeable quantile sketch based on DDSketch, which guarantees
+// quantiles are estimated within a configurable relative accuracy of the
+// actual value.
+//
+// See https://arxiv.org/abs/1908.10693
+//
+// A Sketch is exposed like a Prometheus summary:
+//
+//	<metric_name>{<optional_tags>,quantile="<q>"} <value>
+//	<metric_name>_sum{<optional_tags>} <sum>
+//	<metric_name>_count{<optional_tags>} <count>
+//
+// Unlike a summary, Sketches from many processes can be combined with
+// [Sketch.Merge], and shipped elsewhere using [Sketch.MarshalBinary] and
+// [Sketch.UnmarshalBinary] without losing accuracy.
+//
+// To bound memory, at most 2048 bins are kept for each of positive and
+// negative values, after which the lowest bins are collapsed together and
+// lose their accuracy guarantee.
+//
+// Updates are serialized with a mutex.
+//
+// Zero Sketch is usable as the target of [Sketch.UnmarshalBinary], and
+// otherwise uses a relative accuracy of 1%.
+type Sketch struct {
+	quantiles      []float64
+	quantileValues []Value
+
+	mu       sync.Mutex
+	mapping  sketchMapping
+	positive sketchStore
+	negative sketchStore
+	zero     uint64
+	count    uint64
+	sum      float64
+	min, max float64
+}
+
+func newSketch(relativeAccuracy float64, quantiles []float64) *Sketch {
+	if len(quantiles) == 0 {
+		quantiles = DefQuantiles
+	}
+	values := make([]Value, len(quantiles))
+	for i, q := range quantiles {
+		if q < 0 || q > 1 || math.IsNaN(q) {
+			panic("metrics: quantiles must be between 0 and 1")
+		}
+		values[i] = MustValue(strconv.FormatFloat(q, 'f', -1, 64))
+	}
+	return &Sketch{
+		quantiles:      slices.Clone(quantiles),
+		quantileValues: values,
+		mapping:        newSketchMapping(relativeAccuracy),
+	}
+}
+
+// RelativeAccuracy returns the relative accuracy guaranteed by s.
+func (s *Sketch) RelativeAccuracy() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	return s.mapping.relativeAccuracy
+}
+
+// Update updates s with val.
+//
+// NaNs and infinities are ignored.
+func (s *Sketch) Update(val float64) {
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return
+	}
+
+	s.mu.Lock()
+	s.init()
+	switch {
+	case val > 0:
+		s.positive.add(s.mapping.index(val), 1)
+	case val < 0:
+		s.negative.add(s.mapping.index(-val), 1)
+	default:
+		s.zero++
+	}
+	if s.count == 0 {
+		s.min, s.max = val, val
+	} else {
+		s.min = min(s.min, val)
+		s.max = max(s.max, val)
+	}
+	s.count++
+	s.sum += val
+	s.mu.Unlock()
+}
+
+// Observe updates s with val, identical to [Sketch.Update].
+//
+// NaNs and infinities are ignored.
+func (s *Sketch) Observe(val float64) {
+	s.Update(val)
+}
+
+// UpdateDuration updates request duration based on the given startTime.
+func (s *Sketch) UpdateDuration(startTime time.Time) {
+	s.Update(time.Since(startTime).Seconds())
+}
+
+// Reset resets the given Sketch.
+func (s *Sketch) Reset() {
+	s.mu.Lock()
+	s.positive.reset()
+	s.negative.reset()
+	s.zero = 0
+	s.count = 0
+	s.sum = 0
+	s.min, s.max = 0, 0
+	s.mu.Unlock()
+}
+
+// Count returns the number of observations in s.
+func (s *Sketch) Count() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Sum returns the sum of all observations in s.
+func (s *Sketch) Sum() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sum
+}
+
+// Quantile returns an estimate of the q-quantile (0 <= q <= 1) within the
+// relative accuracy of s.
+//
+// Returns NaN if s has no observations or q is out of range.
+func (s *Sketch) Quantile(q float64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quantile(q)
+}
+
+// Merge merges src to s.
+//
+// This will panic if src does not have the same relative accuracy as s.
+func (s *Sketch) Merge(src *Sketch) {
+	if s == src {
+		panic("metrics: cannot merge a Sketch into itself")
+	}
+
+	// copy src first so we never hold both locks at once
+	var c Sketch
+	src.mu.Lock()
+	src.init()
+	c.mapping = src.mapping
+	c.positive = src.positive.clone()
+	c.negative = src.negative.clone()
+	c.zero, c.count, c.sum, c.min, c.max = src.zero, src.count, src.sum, src.min, src.max
+	src.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	if s.mapping != c.mapping {
+		panic("metrics: cannot merge Sketches with different relative accuracy")
+	}
+	s.merge(&c)
+}
+
+// merge merges c into s, must be called with the lock held.
+func (s *Sketch) merge(c *Sketch) {
+	if c.count == 0 {
+		return
+	}
+	c.positive.each(func(idx int, n uint64) { s.positive.add(idx, n) })
+	c.negative.each(func(idx int, n uint64) { s.negative.add(idx, n) })
+	if s.count == 0 {
+		s.min, s.max = c.min, c.max
+	} else {
+		s.min = min(s.min, c.min)
+		s.max = max(s.max, c.max)
+	}
+	s.zero += c.zero
+	s.count += c.count
+	s.sum += c.sum
+}
+
+// MarshalBinary implements [encoding.BinaryMarshaler].
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+
+	b := make([]byte, 0, 64+
+		binary.MaxVarintLen64*(len(s.positive.counts)+len(s.negative.counts)))
+	b = append(b, sketchEncodingVersion)
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(s.mapping.relativeAccuracy))
+	b = binary.AppendUvarint(b, s.zero)
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(s.sum))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(s.min))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(s.max))
+	b = s.positive.appendBinary(b)
+	b = s.negative.appendBinary(b)
+	return b, nil
+}
+
+// UnmarshalBinary implements [encoding.BinaryUnmarshaler], replacing all
+// observations and the relative accuracy of s with the decoded ones.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errSketchEncodingShort
+	}
+	if data[0] != sketchEncodingVersion {
+		return errSketchEncoding
+	}
+	data = data[1:]
+
+	var c Sketch
+	var accuracy float64
+	var err error
+	if accuracy, data, err = readSketchFloat64(data); err != nil {
+		return err
+	}
+	if !(accuracy > 0 && accuracy < 1) {
+		return errSketchEncoding
+	}
+	c.mapping = newSketchMapping(accuracy)
+
+	var n int
+	if c.zero, n = binary.Uvarint(data); n <= 0 {
+		return errSketchEncodingShort
+	}
+	data = data[n:]
+	if c.sum, data, err = readSketchFloat64(data); err != nil {
+		return err
+	}
+	if c.min, data, err = readSketchFloat64(data); err != nil {
+		return err
+	}
+	if c.max, data, err = readSketchFloat64(data); err != nil {
+		return err
+	}
+	if data, err = c.positive.readBinary(data, c.mapping); err != nil {
+		return err
+	}
+	if data, err = c.negative.readBinary(data, c.mapping); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		return errSketchEncoding
+	}
+	c.count = c.zero + c.positive.total() + c.negative.total()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mapping = c.mapping
+	s.positive, s.negative = c.positive, c.negative
+	s.zero, s.count, s.sum, s.min, s.max = c.zero, c.count, c.sum, c.min, c.max
+	return nil
+}
+
+// init lazily initializes a zero Sketch, must be called with the lock held.
+func (s *Sketch) init() {
+	if s.mapping.gamma == 0 {
+		s.mapping = newSketchMapping(0)
+	}
+}
+
+// quantile must be called with the lock held.
+func (s *Sketch) quantile(q float64) float64 {
+	if s.count == 0 || !(q >= 0 && q <= 1) {
+		return math.NaN()
+	}
+
+	rank := uint64(q * float64(s.count-1))
+	var v float64
+	switch {
+	case rank < s.negative.total():
+		// negative values are stored by magnitude, so walk them from
+		// the largest magnitude down
+		rank = s.negative.total() - 1 - rank
+		v = -s.mapping.value(s.negative.indexAtRank(rank))
+	case rank < s.negative.total()+s.zero:
+		v = 0
+	default:
+		rank -= s.negative.total() + s.zero
+		v = s.mapping.value(s.positive.indexAtRank(rank))
+	}
+
+	// the true value can never be beyond the observed extremes
+	return min(max(v, s.min), s.max)
+}
+
+// summary returns the quantiles of s, along with the count and sum of all
+// observations, as they are written by marshalTo.
+func (s *Sketch) summary() (quantiles []Quantile, count uint64, sum float64) {
+	quantiles = make([]Quantile, len(s.quantiles))
+
+	s.mu.Lock()
+	s.init()
+	for i, q := range s.quantiles {
+		quantiles[i] = Quantile{Quantile: q, Value: s.quantile(q)}
+	}
+	count, sum = s.count, s.sum
+	s.mu.Unlock()
+	return quantiles, count, sum
+}
+
+func (s *Sketch) marshalTo(w ExpfmtWriter, name MetricName) {
+	// avoid allocating for a reasonable number of quantiles
+	var stack [8]float64
+	var values []float64
+	if len(s.quantiles) > len(stack) {
+		values = make([]float64, len(s.quantiles))
+	} else {
+		values = stack[:len(s.quantiles)]
+	}
+
+	s.mu.Lock()
+	s.init()
+	for i, q := range s.quantiles {
+		values[i] = s.quantile(q)
+	}
+	sum, count := s.sum, s.count
+	s.mu.Unlock()
+
+	labels := [1]Label{quantileLabel}
+	for i := range values {
+		w.WriteMetricNameWithVariableTags(name, labels[:], s.quantileValues[i:i+1])
+		w.WriteFloat64(values[i])
+	}
+
+	family := name.Family.String()
+	b := w.b
+
+	b.WriteString(family)
+	b.WriteString("_sum")
+	if len(w.constantTags) > 0 || name.hasTags() {
+		b.WriteByte('{')
+		writeTags(b, w.constantTags, name.Tags)
+		b.WriteByte('}')
+	}
+	w.WriteFloat64(sum)
+
+	b.WriteString(family)
+	b.WriteString("_count")
+	if len(w.constantTags) > 0 || name.hasTags() {
+		b.WriteByte('{')
+		writeTags(b, w.constantTags, name.Tags)
+		b.WriteByte('}')
+	}
+	w.WriteUint64(count)
+}
+
+// sketchMapping maps values to logarithmically sized bins.
+type sketchMapping struct {
+	relativeAccuracy float64
+	gamma            float64
+	multiplier       float64
+}
+
+func newSketchMapping(relativeAccuracy float64) sketchMapping {
+	if relativeAccuracy == 0 {
+		relativeAccuracy = defaultSketchAccuracy
+	}
+	if !(relativeAccuracy > 0 && relativeAccuracy < 1) {
+		panic("metrics: relative accuracy must be between 0 and 1")
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return sketchMapping{
+		relativeAccuracy: relativeAccuracy,
+		gamma:            gamma,
+		multiplier:       1 / math.Log(gamma),
+	}
+}
+
+// index returns the bin for a positive value v.
+func (m sketchMapping) index(v float64) int {
+	return int(math.Ceil(math.Log(v) * m.multiplier))
+}
+
+// indexRange returns the lowest and highest bins of positive finite values.
+func (m sketchMapping) indexRange() (lo, hi int) {
+	return m.index(math.SmallestNonzeroFloat64), m.index(math.MaxFloat64)
+}
+
+// value returns the representative value for the bin at idx, which is
+// within the relative accuracy of every value that maps to it.
+func (m sketchMapping) value(idx int) float64 {
+	return math.Pow(m.gamma, float64(idx)) * 2 / (1 + m.gamma)
+}
+
+// sketchStore is a dense store of bin counts starting at offset.
+type sketchStore struct {
+	offset int
+	counts []uint64
+}
+
+func (s *sketchStore) reset() {
+	s.offset = 0
+	s.counts = s.counts[:0]
+}
+
+func (s *sketchStore) clone() sketchStore {
+	return sketchStore{
+		offset: s.offset,
+		counts: append([]uint64(nil), s.counts...),
+	}
+}
+
+func (s *sketchStore) total() uint64 {
+	var total uint64
+	for _, n := range s.counts {
+		total += n
+	}
+	return total
+}
+
+func (s *sketchStore) each(f func(idx int, n uint64)) {
+	for i, n := range s.counts {
+		if n > 0 {
+			f(s.offset+i, n)
+		}
+	}
+}
+
+func (s *sketchStore) add(idx int, n uint64) {
+	if len(s.counts) == 0 {
+		s.offset = idx
+		s.counts = append(s.counts, n)
+		return
+	}
+
+	end := s.offset + len(s.counts)
+	switch {
+	case idx < s.offset:
+		if end-idx > sketchMaxBins {
+			// collapse into the lowest bin
+			s.counts[0] += n
+			return
+		}
+		counts := make([]uint64, end-idx, max(end-idx, cap(s.counts)))
+		copy(counts[s.offset-idx:], s.counts)
+		s.counts = counts
+		s.offset = idx
+	case idx >= end:
+		if size := idx - s.offset + 1; size > sketchMaxBins {
+			// collapse the lowest bins to make room
+			s.collapse(size - sketchMaxBins)
+		}
+		for s.offset+len(s.counts) <= idx {
+			s.counts = append(s.counts, 0)
+		}
+	}
+	s.counts[idx-s.offset] += n
+}
+
+// collapse merges the lowest n bins into the bin that follows them.
+func (s *sketchStore) collapse(n int) {
+	if n >= len(s.counts) {
+		total := s.total()
+		s.offset += n
+		s.counts = append(s.counts[:0], total)
+		return
+	}
+
+	var total uint64
+	for _, c := range s.counts[:n] {
+		total += c
+	}
+	s.counts = append(s.counts[:0], s.counts[n:]...)
+	s.counts[0] += total
+	s.offset += n
+}
+
+// indexAtRank returns the bin holding the 0-based rank.
+func (s *sketchStore) indexAtRank(rank uint64) int {
+	var seen uint64
+	for i, n := range s.counts {
+		seen += n
+		if seen > rank {
+			return s.offset + i
+		}
+	}
+	return s.offset + len(s.counts) - 1
+}
+
+func (s *sketchStore) appendBinary(b []byte) []byte {
+	b = binary.AppendVarint(b, int64(s.offset))
+	b = binary.AppendUvarint(b, uint64(len(s.counts)))
+	for _, n := range s.counts {
+		b = binary.AppendUvarint(b, n)
+	}
+	return b
+}
+
+// readBinary decodes the store, rejecting bins outside of those m maps
+// values to.
+func (s *sketchStore) readBinary(data []byte, m sketchMapping) ([]byte, error) {
+	offset, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, errSketchEncodingShort
+	}
+	data = data[n:]
+
+	size, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errSketchEncodingShort
+	}
+	data = data[n:]
+	if size > sketchMaxBins || size > uint64(len(data)) {
+		return nil, errSketchEncoding
+	}
+	// the bins [offset, offset+size) must be within those of finite values
+	lo, hi := m.indexRange()
+	if offset < int64(lo) || offset > int64(hi) || int64(size) > int64(hi)-offset+1 {
+		return nil, errSketchEncoding
+	}
+
+	s.offset = int(offset)
+	s.counts = make([]uint64, size)
+	for i := range s.counts {
+		if s.counts[i], n = binary.Uvarint(data); n <= 0 {
+			return nil, errSketchEncodingShort
+		}
+		data = data[n:]
+	}
+	return data, nil
+}
+
+func readSketchFloat64(data []byte) (float64, []byte, error) {
+	if len(data) < 8 {
+		return 0, nil, errSketchEncodingShort
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:], nil
+}
@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestSketchNew(t *testing.T) {
+	NewSet().NewSketch("foo", 0, nil)
+	NewSet().NewSketch("foo", 0.05, []float64{0.5}, "bar", "baz")
+
+	// invalid label pairs
+	assert.Panics(t, func() { NewSet().NewSketch("foo", 0, nil, "bar") })
+
+	// invalid accuracy and quantiles
+	assert.Panics(t, func() { NewSet().NewSketch("foo", 1, nil) })
+	assert.Panics(t, func() { NewSet().NewSketch("foo", -0.1, nil) })
+	assert.Panics(t, func() { NewSet().NewSketch("foo", 0, []float64{1.5}) })
+
+	// duplicate
+	set := NewSet()
+	set.NewSketch("foo", 0, nil)
+	assert.Panics(t, func() { set.NewSketch("foo", 0, nil) })
+
+	// quantiles are copied from the caller
+	quantiles := []float64{0.5}
+	s := NewSet().NewSketch("foo", 0, quantiles)
+	quantiles[0] = 0.9
+	assert.SlicesEqual(t, s.quantiles, []float64{0.5})
+}
+
+func TestSketchSerial(t *testing.T) {
+	set := NewSet()
+	s := set.NewSketch("sketch", 0.01, []float64{0, 0.5, 1}, "a", "b")
+
+	assertMarshal(t, set, []string{
+		`sketch{a="b",quantile="0"} NaN`,
+		`sketch{a="b",quantile="0.5"} NaN`,
+		`sketch{a="b",quantile="1"} NaN`,
+		`sketch_sum{a="b"} 0`,
+		`sketch_count{a="b"} 0`,
+	})
+
+	for i := 1; i <= 100; i++ {
+		s.Update(float64(i))
+	}
+	s.Update(math.NaN())
+	s.Update(math.Inf(1))
+
+	assertMarshal(t, set, []string{
+		`sketch{a="b",quantile="0"} 1`,
+		`sketch{a="b",quantile="0.5"} 49.90296094906597`,
+		`sketch{a="b",quantile="1"} 100`,
+		`sketch_sum{a="b"} 5050`,
+		`sketch_count{a="b"} 100`,
+	})
+
+	s.Reset()
+	assert.Equal(t, s.Count(), 0)
+	assert.True(t, math.IsNaN(s.Quantile(0.5)))
+}
+
+func TestSketchRelativeAccuracy(t *testing.T) {
+	const accuracy = 0.02
+
+	var values []float64
+	s := newSketch(accuracy, nil)
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 10000 {
+		v := r.ExpFloat64() * 100
+		if r.IntN(10) == 0 {
+			v = -v
+		}
+		values = append(values, v)
+		s.Update(v)
+	}
+	s.Update(0)
+	values = append(values, 0)
+	slices.Sort(values)
+
+	for _, q := range []float64{0, 0.01, 0.05, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 1} {
+		want := values[int(q*float64(len(values)-1))]
+		got := s.Quantile(q)
+		assert.True(t, math.Abs(got-want) <= accuracy*math.Abs(want),
+			assert.Sprintf("q=%v got=%v want=%v", q, got, want))
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	a := newSketch(0.01, nil)
+	b := newSketch(0.01, nil)
+	all := newSketch(0.01, nil)
+	for i := range 1000 {
+		v := float64(i) - 100
+		if i%2 == 0 {
+			a.Update(v)
+		} else {
+			b.Update(v)
+		}
+		all.Update(v)
+	}
+
+	a.Merge(b)
+	assert.Equal(t, a.Count(), all.Count())
+	assert.Equal(t, a.Sum(), all.Sum())
+	for _, q := range DefQuantiles {
+		assert.Equal(t, a.Quantile(q), all.Quantile(q))
+	}
+
+	assert.Panics(t, func() { a.Merge(a) })
+	assert.Panics(t, func() { a.Merge(newSketch(0.05, nil)) })
+}
+
+func TestSketchMarshalBinary(t *testing.T) {
+	s := newSketch(0.05, nil)
+	for i := range 1000 {
+		s.Update(float64(i)/10 - 5)
+	}
+
+	data, err := s.MarshalBinary()
+	assert.Nil(t, err)
+
+	// a zero Sketch takes on the encoded accuracy
+	var s2 Sketch
+	assert.Nil(t, s2.UnmarshalBinary(data))
+	assert.Equal(t, s2.RelativeAccuracy(), 0.05)
+	assert.Equal(t, s2.Count(), s.Count())
+	assert.Equal(t, s2.Sum(), s.Sum())
+	for _, q := range DefQuantiles {
+		assert.Equal(t, s2.Quantile(q), s.Quantile(q))
+	}
+
+	data2, err := s2.MarshalBinary()
+	assert.Nil(t, err)
+	assert.SlicesEqual(t, data2, data)
+
+	for i := range data {
+		assert.NotNil(t, s2.UnmarshalBinary(data[:i]))
+	}
+	assert.NotNil(t, s2.UnmarshalBinary(append(data, 0)))
+}
+
+func TestSketchUnmarshalBinaryOffsets(t *testing.T) {
+	m := newSketchMapping(0.05)
+	lo, hi := m.indexRange()
+
+	encode := func(offset int64, size int) []byte {
+		b := []byte{sketchEncodingVersion}
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(0.05))
+		b = binary.AppendUvarint(b, 0)
+		b = binary.LittleEndian.AppendUint64(b, 0)
+		b = binary.LittleEndian.AppendUint64(b, 0)
+		b = binary.LittleEndian.AppendUint64(b, 0)
+		b = binary.AppendVarint(b, offset)
+		b = binary.AppendUvarint(b, uint64(size))
+		for range size {
+			b = binary.AppendUvarint(b, 1)
+		}
+		// an empty negative store
+		return binary.AppendUvarint(binary.AppendVarint(b, 0), 0)
+	}
+
+	for _, tt := range []struct {
+		offset int64
+		size   int
+		ok     bool
+	}{
+		{0, 1, true},
+		{int64(lo), 3, true},
+		{int64(hi) - 2, 3, true},
+		{int64(lo) - 1, 3, false},
+		{int64(hi) - 1, 3, false},
+		{math.MaxInt64, 1, false},
+		{math.MaxInt64 - 1, 2, false},
+		{math.MinInt64, 1, false},
+		{math.MaxInt32, 1, false},
+	} {
+		var s Sketch
+		err := s.UnmarshalBinary(encode(tt.offset, tt.size))
+		if !tt.ok {
+			assert.ErrorIs(t, err, errSketchEncoding)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, s.Count(), uint64(tt.size))
+
+		// the decoded Sketch can still be updated, merged and queried
+		s.Update(1)
+		s.Update(math.MaxFloat64)
+		s.Update(math.SmallestNonzeroFloat64)
+		merged := newSketch(0.05, nil)
+		merged.Update(1)
+		merged.Merge(&s)
+		assert.False(t, math.IsNaN(merged.Quantile(0.5)))
+	}
+}
+
+func TestSketchCollapse(t *testing.T) {
+	s := newSketch(0.01, nil)
+	s.Update(1e-300)
+	s.Update(1)
+	s.Update(1e300)
+
+	assert.True(t, len(s.positive.counts) <= sketchMaxBins)
+	assert.Equal(t, s.Count(), 3)
+	// the highest values retain their accuracy, while the lowest ones
+	// have been collapsed together
+	assert.True(t, math.Abs(s.Quantile(1)-1e300) <= 0.01*1e300)
+	assert.Greater(t, s.Quantile(0), 1.0)
+}
+
+func TestSketchConcurrent(t *testing.T) {
+	const n = 5
+
+	s := newSketch(0, nil)
+	hammer(t, n, func(_ int) {
+		for f := 0.6; f < 1.4; f += 0.1 {
+			s.Update(f)
+		}
+	})
+	assert.Equal(t, s.Count(), 40)
+}
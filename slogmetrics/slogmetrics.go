@@ -0,0 +1,157 @@
+/*
+Package slogmetrics provides a [slog.Handler] that counts log records.
+
+Records are counted by level, and optionally by the value of an attribute
+such as "component", before being passed to the wrapped handler.
+
+For example:
+
+	logger := slog.New(slogmetrics.NewHandler(
+		slog.NewJSONHandler(os.Stderr, nil),
+		slogmetrics.WithAttr("component"),
+	))
+*/
+package slogmetrics
+
+import (
+	"context"
+	"log/slog"
+
+	"go.withmatt.com/metrics"
+)
+
+// Option configures a Handler.
+type Option func(*config)
+
+type config struct {
+	attr string
+}
+
+// WithAttr additionally counts records by the value of the top level
+// attribute key, using key as the label. Records without the attribute are
+// counted with an empty value.
+//
+// The attribute values must have bounded cardinality.
+func WithAttr(key string) Option {
+	return func(c *config) { c.attr = key }
+}
+
+// NewHandler wraps next, recording into the global metrics Set.
+// See [NewHandlerFor].
+func NewHandler(next slog.Handler, opts ...Option) slog.Handler {
+	return newHandler(metrics.NewUint64Vec, next, opts)
+}
+
+// NewHandlerFor wraps next, recording the following metrics into set:
+//
+//   - log_records_total{level}
+//   - log_errors_total{level}
+//
+// Where level is the [slog.Level] of the record, such as "ERROR", and
+// log_errors_total counts records which next failed to handle. If [WithAttr]
+// is used, both are also labelled by the attribute.
+//
+// Only records enabled by next are counted.
+func NewHandlerFor(set *metrics.Set, next slog.Handler, opts ...Option) slog.Handler {
+	return newHandler(set.NewUint64Vec, next, opts)
+}
+
+func newHandler(
+	newVec func(family string, labels ...string) *metrics.Uint64Vec,
+	next slog.Handler,
+	opts []Option,
+) slog.Handler {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	labels := []string{"level"}
+	if cfg.attr != "" {
+		labels = append(labels, cfg.attr)
+	}
+	return &handler{
+		next: next,
+		counters: &counters{
+			attr:    cfg.attr,
+			records: newVec("log_records_total", labels...),
+			errors:  newVec("log_errors_total", labels...),
+		},
+	}
+}
+
+// counters are shared by a handler and those derived from it.
+type counters struct {
+	attr    string
+	records *metrics.Uint64Vec
+	errors  *metrics.Uint64Vec
+}
+
+type handler struct {
+	next     slog.Handler
+	counters *counters
+
+	// attrValue is the value of the attribute if set by WithAttrs
+	attrValue string
+	// grouped is set once attributes are no longer top level
+	grouped bool
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	c := h.counters
+	values := []string{metrics.SanitizeValue(r.Level.String()).String()}
+	if c.attr != "" {
+		// attribute values are arbitrary, and must not panic as tag values
+		values = append(values, metrics.SanitizeValue(h.recordAttrValue(r)).String())
+	}
+
+	c.records.WithLabelValues(values...).Inc()
+	err := h.next.Handle(ctx, r)
+	if err != nil {
+		c.errors.WithLabelValues(values...).Inc()
+	}
+	return err
+}
+
+// recordAttrValue returns the value of the counted attribute for r.
+func (h *handler) recordAttrValue(r slog.Record) string {
+	value := h.attrValue
+	if h.grouped {
+		// the record's attributes belong to the group
+		return value
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == h.counters.attr {
+			value = a.Value.Resolve().String()
+			return false
+		}
+		return true
+	})
+	return value
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithAttrs(attrs)
+	if h.counters.attr != "" && !h.grouped {
+		for _, a := range attrs {
+			if a.Key == h.counters.attr {
+				h2.attrValue = a.Value.Resolve().String()
+			}
+		}
+	}
+	return &h2
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+	h2.grouped = true
+	return &h2
+}
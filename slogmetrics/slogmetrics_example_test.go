@@ -0,0 +1,28 @@
+package slogmetrics_test
+
+import (
+	"log/slog"
+	"os"
+
+	"go.withmatt.com/metrics"
+	"go.withmatt.com/metrics/slogmetrics"
+)
+
+func ExampleNewHandler() {
+	logger := slog.New(slogmetrics.NewHandler(slog.NewJSONHandler(os.Stderr, nil)))
+	slog.SetDefault(logger)
+
+	// Counted as log_records_total{level="ERROR"}.
+	slog.Error("request failed")
+}
+
+func ExampleWithAttr() {
+	set := metrics.NewSet()
+	logger := slog.New(slogmetrics.NewHandlerFor(set,
+		slog.NewJSONHandler(os.Stderr, nil),
+		slogmetrics.WithAttr("component"),
+	))
+
+	// Counted as log_records_total{level="WARN",component="db"}.
+	logger.With("component", "db").Warn("slow query")
+}
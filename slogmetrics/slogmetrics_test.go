@@ -0,0 +1,114 @@
+package slogmetrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics"
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestHandler(t *testing.T) {
+	set := metrics.NewSet()
+	var buf bytes.Buffer
+	logger := slog.New(NewHandlerFor(set, slog.NewTextHandler(&buf, nil)))
+
+	logger.Debug("not enabled")
+	logger.Info("one")
+	logger.Info("two")
+	logger.Error("three")
+
+	assert.Equal(t, strings.Count(buf.String(), "\n"), 3)
+	lines := writeLines(set)
+	for _, want := range []string{
+		`log_records_total{level="INFO"} 2`,
+		`log_records_total{level="ERROR"} 1`,
+	} {
+		assert.True(t, slices.Contains(lines, want), assert.Sprintf("missing %s", want))
+	}
+	for _, line := range lines {
+		assert.False(t, strings.Contains(line, "DEBUG"), assert.Sprintf("unexpected %s", line))
+	}
+}
+
+func TestHandlerWithAttr(t *testing.T) {
+	set := metrics.NewSet()
+	var buf bytes.Buffer
+	logger := slog.New(NewHandlerFor(set, slog.NewTextHandler(&buf, nil), WithAttr("component")))
+
+	logger.Info("record attr", "component", "db")
+	logger.With("component", "http").Warn("handler attr")
+	logger.With("component", "http").Warn("record overrides", "component", "cache")
+	logger.Info("missing")
+	// attributes within a group are not top level
+	logger.WithGroup("g").Info("grouped", "component", "db")
+	logger.With("component", "http").WithGroup("g").Info("grouped", "component", "db")
+
+	lines := writeLines(set)
+	for _, want := range []string{
+		`log_records_total{level="INFO",component="db"} 1`,
+		`log_records_total{level="WARN",component="http"} 1`,
+		`log_records_total{level="WARN",component="cache"} 1`,
+		`log_records_total{level="INFO",component=""} 2`,
+		`log_records_total{level="INFO",component="http"} 1`,
+	} {
+		assert.True(t, slices.Contains(lines, want), assert.Sprintf("missing %s", want))
+	}
+}
+
+func TestHandlerSanitizesAttr(t *testing.T) {
+	set := metrics.NewSet()
+	var buf bytes.Buffer
+	logger := slog.New(NewHandlerFor(set, slog.NewTextHandler(&buf, nil), WithAttr("path")))
+
+	logger.Info("req", "path", `/a"b`)
+	logger.Info("req", "path", `/a\b`)
+	logger.With("path", "/a\nb").Info("req")
+
+	lines := writeLines(set)
+	for _, want := range []string{
+		`log_records_total{level="INFO",path="/a\"b"} 1`,
+		`log_records_total{level="INFO",path="/a\\b"} 1`,
+		`log_records_total{level="INFO",path="/a\nb"} 1`,
+	} {
+		assert.True(t, slices.Contains(lines, want), assert.Sprintf("missing %s", want))
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	set := metrics.NewSet()
+	logger := slog.New(NewHandlerFor(set, errorHandler{}))
+
+	logger.Warn("fails")
+
+	lines := writeLines(set)
+	for _, want := range []string{
+		`log_records_total{level="WARN"} 1`,
+		`log_errors_total{level="WARN"} 1`,
+	} {
+		assert.True(t, slices.Contains(lines, want), assert.Sprintf("missing %s", want))
+	}
+}
+
+type errorHandler struct{}
+
+func (errorHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (errorHandler) Handle(context.Context, slog.Record) error {
+	return errors.New("write failed")
+}
+
+func (h errorHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h errorHandler) WithGroup(string) slog.Handler { return h }
+
+func writeLines(set *metrics.Set) []string {
+	var b bytes.Buffer
+	set.WritePrometheus(&b)
+	return strings.Split(strings.TrimSpace(b.String()), "\n")
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"database/sql"
+	"maps"
+	"slices"
+)
+
+// sqlStat is a family of [sql.DBStats], written for each database.
+type sqlStat struct {
+	family Ident
+	write  func(w MetricWriter, name MetricName, stats sql.DBStats)
+}
+
+var sqlStats = [...]sqlStat{
+	{MustIdent("go_sql_max_open_connections"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteGaugeInt64(name, int64(stats.MaxOpenConnections))
+	}},
+	{MustIdent("go_sql_open_connections"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteGaugeInt64(name, int64(stats.OpenConnections))
+	}},
+	{MustIdent("go_sql_in_use_connections"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteGaugeInt64(name, int64(stats.InUse))
+	}},
+	{MustIdent("go_sql_idle_connections"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteGaugeInt64(name, int64(stats.Idle))
+	}},
+	{MustIdent("go_sql_wait_count_total"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteCounterUint64(name, uint64(stats.WaitCount))
+	}},
+	{MustIdent("go_sql_wait_duration_seconds_total"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteCounterFloat64(name, stats.WaitDuration.Seconds())
+	}},
+	{MustIdent("go_sql_max_idle_closed_total"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteCounterUint64(name, uint64(stats.MaxIdleClosed))
+	}},
+	{MustIdent("go_sql_max_idle_time_closed_total"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteCounterUint64(name, uint64(stats.MaxIdleTimeClosed))
+	}},
+	{MustIdent("go_sql_max_lifetime_closed_total"), func(w MetricWriter, name MetricName, stats sql.DBStats) {
+		w.WriteCounterUint64(name, uint64(stats.MaxLifetimeClosed))
+	}},
+}
+
+// NewSQLStatsCollector is a [MetricCollector] that yields connection pool
+// statistics from [sql.DB.Stats] for each database in dbs, tagged with its
+// name as `db`. Metrics are prefixed with `go_sql_`.
+//
+// Connection starvation shows up as a growing go_sql_wait_count_total and
+// go_sql_wait_duration_seconds_total while go_sql_in_use_connections is at
+// go_sql_max_open_connections.
+func NewSQLStatsCollector(dbs map[string]*sql.DB) Collector {
+	c := &sqlStatsCollector{
+		dbs: make([]sqlStatsDB, 0, len(dbs)),
+	}
+	for _, name := range slices.Sorted(maps.Keys(dbs)) {
+		db := dbs[name]
+		if db == nil {
+			panic("metrics: nil *sql.DB for db " + name)
+		}
+		c.dbs = append(c.dbs, sqlStatsDB{
+			db:   db,
+			tags: []Tag{MustTag("db", name)},
+		})
+	}
+	return c
+}
+
+type sqlStatsCollector struct {
+	dbs []sqlStatsDB
+}
+
+type sqlStatsDB struct {
+	db   *sql.DB
+	tags []Tag
+}
+
+func (c *sqlStatsCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
+
+func (c *sqlStatsCollector) CollectMetrics(w MetricWriter) {
+	stats := make([]sql.DBStats, len(c.dbs))
+	for i, sdb := range c.dbs {
+		stats[i] = sdb.db.Stats()
+	}
+
+	// write each family for all databases together
+	for _, stat := range sqlStats {
+		for i, sdb := range c.dbs {
+			stat.write(w, MetricName{Family: stat.family, Tags: sdb.tags}, stats[i])
+		}
+	}
+}
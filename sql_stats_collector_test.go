@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+type testConnector struct{}
+
+func (testConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (testConnector) Driver() driver.Driver { return nil }
+
+func TestSQLStatsCollector(t *testing.T) {
+	primary := sql.OpenDB(testConnector{})
+	defer primary.Close()
+	primary.SetMaxOpenConns(10)
+
+	replica := sql.OpenDB(testConnector{})
+	defer replica.Close()
+
+	set := NewSet()
+	set.RegisterCollector(NewSQLStatsCollector(map[string]*sql.DB{
+		"replica": replica,
+		"primary": primary,
+	}))
+	assertMarshal(t, set, []string{
+		`go_sql_max_open_connections{db="primary"} 10`,
+		`go_sql_max_open_connections{db="replica"} 0`,
+		`go_sql_open_connections{db="primary"} 0`,
+		`go_sql_open_connections{db="replica"} 0`,
+		`go_sql_in_use_connections{db="primary"} 0`,
+		`go_sql_in_use_connections{db="replica"} 0`,
+		`go_sql_idle_connections{db="primary"} 0`,
+		`go_sql_idle_connections{db="replica"} 0`,
+		`go_sql_wait_count_total{db="primary"} 0`,
+		`go_sql_wait_count_total{db="replica"} 0`,
+		`go_sql_wait_duration_seconds_total{db="primary"} 0`,
+		`go_sql_wait_duration_seconds_total{db="replica"} 0`,
+		`go_sql_max_idle_closed_total{db="primary"} 0`,
+		`go_sql_max_idle_closed_total{db="replica"} 0`,
+		`go_sql_max_idle_time_closed_total{db="primary"} 0`,
+		`go_sql_max_idle_time_closed_total{db="replica"} 0`,
+		`go_sql_max_lifetime_closed_total{db="primary"} 0`,
+		`go_sql_max_lifetime_closed_total{db="replica"} 0`,
+	})
+
+	families, err := set.Gather()
+	assert.Nil(t, err)
+	for _, f := range families {
+		want := TypeGauge
+		if strings.HasSuffix(f.Name, "_total") {
+			want = TypeCounter
+		}
+		assert.Equal(t, f.Type, want, assert.Sprintf("%s", f.Name))
+	}
+
+	assert.Panics(t, func() {
+		NewSQLStatsCollector(map[string]*sql.DB{"nil": nil})
+	})
+}
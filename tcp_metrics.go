@@ -0,0 +1,32 @@
+package metrics
+
+// NewTCPSocketCollector is a [MetricCollector] that yields the TCP sockets of the
+// process, found by matching the socket inodes of its file descriptors
+// against /proc/net/tcp and /proc/net/tcp6:
+//
+//   - process_tcp_sockets{state}
+//   - process_tcp_inbound_sockets{port, state}
+//
+// Where state is the lowercase TCP state, such as "established" or
+// "close_wait". A growing count of close_wait sockets is a sign that
+// connections are not being closed.
+//
+// Sockets in time_wait, or not yet accepted, are no longer associated with
+// a file descriptor, so they are only counted in
+// process_tcp_inbound_sockets, which counts all sockets, other than
+// listeners, with a local port the process is listening on.
+//
+// This is only supported on Linux, and yields nothing elsewhere.
+func NewTCPSocketCollector() Collector {
+	return &tcpSocketCollector{
+		procRoot: "/proc",
+	}
+}
+
+type tcpSocketCollector struct {
+	procRoot string
+}
+
+func (c *tcpSocketCollector) Collect(w ExpfmtWriter) {
+	c.CollectMetrics(w)
+}
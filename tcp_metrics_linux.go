@@ -0,0 +1,174 @@
+//go:build linux
+
+package metrics
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tcpStates are the names of the TCP states, indexed by their value in
+// /proc/net/tcp.
+//
+// See include/net/tcp_states.h
+var tcpStates = [...]string{
+	1:  "established",
+	2:  "syn_sent",
+	3:  "syn_recv",
+	4:  "fin_wait1",
+	5:  "fin_wait2",
+	6:  "time_wait",
+	7:  "close",
+	8:  "close_wait",
+	9:  "last_ack",
+	10: "listen",
+	11: "closing",
+	12: "new_syn_recv",
+}
+
+const tcpListen = 10
+
+// tcpSocket is a socket from /proc/net/tcp.
+type tcpSocket struct {
+	port  uint16
+	state uint8
+	inode uint64
+}
+
+// tcpPortState is a key for counting sockets by local port and state.
+type tcpPortState struct {
+	port  uint16
+	state uint8
+}
+
+func (c *tcpSocketCollector) CollectMetrics(w MetricWriter) {
+	inodes, err := readSocketInodes(filepath.Join(c.procRoot, "self/fd"))
+	if err != nil {
+		return
+	}
+	var sockets []tcpSocket
+	for _, name := range [...]string{"net/tcp", "net/tcp6"} {
+		if data, err := os.ReadFile(filepath.Join(c.procRoot, name)); err == nil {
+			sockets = parseProcNetTCP(data, sockets)
+		}
+	}
+
+	var states [len(tcpStates)]uint64
+	listening := make(map[uint16]bool)
+	for _, s := range sockets {
+		if s.inode == 0 {
+			continue
+		}
+		if _, ok := inodes[s.inode]; !ok {
+			continue
+		}
+		states[s.state]++
+		if s.state == tcpListen {
+			listening[s.port] = true
+		}
+	}
+
+	inbound := make(map[tcpPortState]uint64)
+	for _, s := range sockets {
+		if s.state != tcpListen && listening[s.port] {
+			inbound[tcpPortState{s.port, s.state}]++
+		}
+	}
+
+	for state, name := range tcpStates {
+		if name != "" {
+			w.WriteGaugeUint64(NewMetricName("process_tcp_sockets", "state", name), states[state])
+		}
+	}
+	keys := slices.SortedFunc(maps.Keys(inbound), func(a, b tcpPortState) int {
+		return cmp.Or(cmp.Compare(a.port, b.port), cmp.Compare(a.state, b.state))
+	})
+	for _, k := range keys {
+		w.WriteGaugeUint64(NewMetricName("process_tcp_inbound_sockets",
+			"port", strconv.FormatUint(uint64(k.port), 10),
+			"state", tcpStates[k.state],
+		), inbound[k])
+	}
+}
+
+// readSocketInodes returns the inodes of the sockets open in dir, a
+// /proc/<pid>/fd directory, where each socket is a link to "socket:[inode]".
+func readSocketInodes(dir string) (map[uint64]struct{}, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	inodes := make(map[uint64]struct{})
+	for {
+		names, err := f.Readdirnames(512)
+		for _, name := range names {
+			// the descriptor may have been closed since listing
+			link, err := os.Readlink(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			inode, ok := strings.CutPrefix(link, "socket:[")
+			if !ok {
+				continue
+			}
+			if v, err := strconv.ParseUint(strings.TrimSuffix(inode, "]"), 10, 64); err == nil {
+				inodes[v] = struct{}{}
+			}
+		}
+		switch err {
+		case io.EOF:
+			return inodes, nil
+		case nil:
+			continue
+		default:
+			return nil, err
+		}
+	}
+}
+
+// parseProcNetTCP appends the sockets in /proc/net/tcp or /proc/net/tcp6 to
+// sockets. After a header, each line is a socket such as:
+//
+//	0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12345 ...
+//
+// Where the fields are the local and remote address and port in hex, the
+// state in hex, and the inode is the 10th field.
+func parseProcNetTCP(data []byte, sockets []tcpSocket) []tcpSocket {
+	for line := range bytes.Lines(data) {
+		fields := bytes.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		i := bytes.LastIndexByte(fields[1], ':')
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.ParseUint(string(fields[1][i+1:]), 16, 16)
+		if err != nil {
+			continue
+		}
+		state, err := strconv.ParseUint(string(fields[3]), 16, 8)
+		if err != nil || state >= uint64(len(tcpStates)) || tcpStates[state] == "" {
+			continue
+		}
+		inode, err := strconv.ParseUint(string(fields[9]), 10, 64)
+		if err != nil {
+			continue
+		}
+		sockets = append(sockets, tcpSocket{
+			port:  uint16(port),
+			state: uint8(state),
+			inode: inode,
+		})
+	}
+	return sockets
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestTCPSocketCollector(t *testing.T) {
+	root := t.TempDir()
+	fdDir := filepath.Join(root, "self/fd")
+	assert.Nil(t, os.MkdirAll(fdDir, 0o755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "net"), 0o755))
+	for fd, link := range map[string]string{
+		"0": "/dev/null",
+		"3": "socket:[100]",
+		"4": "socket:[101]",
+		"5": "socket:[102]",
+		"6": "socket:[200]",
+		"7": "pipe:[300]",
+	} {
+		assert.Nil(t, os.Symlink(link, filepath.Join(fdDir, fd)))
+	}
+
+	header := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	tcp := header +
+		// listening on 8080, owned
+		"   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 100 1 0000000000000000 100 0 0 10 0\n" +
+		// accepted connections on 8080, owned
+		"   1: 0100007F:1F90 0100007F:C000 01 00000000:00000000 00:00000000 00000000  1000        0 101 1 0000000000000000 20 4 30 10 -1\n" +
+		"   2: 0100007F:1F90 0100007F:C001 08 00000000:00000000 00:00000000 00000000  1000        0 102 1 0000000000000000 20 4 30 10 -1\n" +
+		// time_wait on 8080, no longer owned
+		"   3: 0100007F:1F90 0100007F:C002 06 00000000:00000000 03:00000000 00000000     0        0 0 3 0000000000000000\n" +
+		// another process listening on 9090
+		"   4: 00000000:2382 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 999 1 0000000000000000 100 0 0 10 0\n"
+	tcp6 := header +
+		// outbound connection, owned
+		"   0: 0000000000000000FFFF00000100007F:D000 0000000000000000FFFF00000100007F:0050 01 00000000:00000000 00:00000000 00000000  1000        0 200 1 0000000000000000 20 4 30 10 -1\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "net/tcp"), []byte(tcp), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "net/tcp6"), []byte(tcp6), 0o644))
+
+	set := NewSet()
+	set.RegisterCollector(&tcpSocketCollector{procRoot: root})
+	assertMarshal(t, set, []string{
+		`process_tcp_sockets{state="established"} 2`,
+		`process_tcp_sockets{state="syn_sent"} 0`,
+		`process_tcp_sockets{state="syn_recv"} 0`,
+		`process_tcp_sockets{state="fin_wait1"} 0`,
+		`process_tcp_sockets{state="fin_wait2"} 0`,
+		`process_tcp_sockets{state="time_wait"} 0`,
+		`process_tcp_sockets{state="close"} 0`,
+		`process_tcp_sockets{state="close_wait"} 1`,
+		`process_tcp_sockets{state="last_ack"} 0`,
+		`process_tcp_sockets{state="listen"} 1`,
+		`process_tcp_sockets{state="closing"} 0`,
+		`process_tcp_sockets{state="new_syn_recv"} 0`,
+		`process_tcp_inbound_sockets{port="8080",state="established"} 1`,
+		`process_tcp_inbound_sockets{port="8080",state="time_wait"} 1`,
+		`process_tcp_inbound_sockets{port="8080",state="close_wait"} 1`,
+	})
+}
+
+func TestTCPSocketCollectorReal(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	accepted, err := ln.Accept()
+	assert.Nil(t, err)
+	defer accepted.Close()
+
+	set := NewSet()
+	set.RegisterCollector(NewTCPSocketCollector())
+	var b bytes.Buffer
+	set.WritePrometheusUnthrottled(&b)
+
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	assert.True(t, strings.Contains(b.String(),
+		`process_tcp_inbound_sockets{port="`+port+`",state="established"} 1`),
+		assert.Sprintf("%s", b.String()))
+}
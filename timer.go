@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"time"
+
+	"go.withmatt.com/metrics/internal/fasttime"
+)
+
+// Observer is a metric that observes float64 values, such as [Histogram],
+// [FixedHistogram] or [Sketch].
+type Observer interface {
+	Observe(val float64)
+}
+
+// Timer measures the duration since it was started, and observes it in
+// seconds into an [Observer].
+//
+// Typical usage is:
+//
+//	t := h.Start()
+//	defer t.ObserveDuration()
+//
+// A Timer is a small value and starting one does not allocate.
+type Timer struct {
+	start    fasttime.Instant
+	observer Observer
+}
+
+// NewTimer starts a new Timer which observes into o. o may be nil if the
+// [Observer] is only known once the timer is stopped, see
+// [Timer.ObserveDurationTo].
+func NewTimer(o Observer) Timer {
+	return Timer{
+		start:    fasttime.Now(),
+		observer: o,
+	}
+}
+
+// Time calls f and observes how long it took into o.
+func Time(o Observer, f func()) time.Duration {
+	t := NewTimer(o)
+	f()
+	return t.ObserveDuration()
+}
+
+// Elapsed returns the duration since t was started.
+func (t Timer) Elapsed() time.Duration {
+	return fasttime.Since(t.start)
+}
+
+// ObserveDuration observes the duration since t was started into the
+// [Observer] the Timer was started with, and returns it. If the Timer has no
+// Observer, the duration is only returned.
+func (t Timer) ObserveDuration() time.Duration {
+	return t.ObserveDurationTo(t.observer)
+}
+
+// ObserveDurationTo observes the duration since t was started into o instead
+// of the [Observer] the Timer was started with, and returns it. This allows
+// choosing the metric once the outcome is known, for instance:
+//
+//	t := metrics.NewTimer(nil)
+//	code := handle()
+//	t.ObserveDurationTo(requestDuration.WithLabelValues(code))
+func (t Timer) ObserveDurationTo(o Observer) time.Duration {
+	d := t.Elapsed()
+	if o != nil {
+		o.Observe(d.Seconds())
+	}
+	return d
+}
+
+// Start starts a new [Timer] observing into h.
+func (h *Histogram) Start() Timer {
+	return NewTimer(h)
+}
+
+// Start starts a new [Timer] observing into h.
+func (h *FixedHistogram) Start() Timer {
+	return NewTimer(h)
+}
+
+// Start starts a new [Timer] observing into h.
+func (h *WindowedHistogram) Start() Timer {
+	return NewTimer(h)
+}
+
+// Start starts a new [Timer] observing into h.
+func (h *DecayingHistogram) Start() Timer {
+	return NewTimer(h)
+}
+
+// Start starts a new [Timer] observing into s.
+func (s *Sketch) Start() Timer {
+	return NewTimer(s)
+}
@@ -0,0 +1,36 @@
+package metrics_test
+
+import (
+	"strconv"
+
+	"go.withmatt.com/metrics"
+)
+
+func ExampleTimer() {
+	h := metrics.NewHistogram("process_duration_seconds")
+
+	func() {
+		defer h.Start().ObserveDuration()
+		processRequest()
+	}()
+}
+
+func ExampleTimer_ObserveDurationTo() {
+	requestDuration := metrics.NewHistogramVec("request_duration_seconds", "code")
+
+	// the status code is only known once the request is done
+	t := metrics.NewTimer(nil)
+	code := handleRequest()
+	t.ObserveDurationTo(requestDuration.WithLabelValues(strconv.Itoa(code)))
+}
+
+func ExampleTime() {
+	h := metrics.NewFixedHistogram("process_duration_seconds", nil)
+	metrics.Time(h, func() {
+		processRequest()
+	})
+}
+
+func handleRequest() int {
+	return 200
+}
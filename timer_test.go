@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+type testObserver []float64
+
+func (o *testObserver) Observe(val float64) {
+	*o = append(*o, val)
+}
+
+func TestTimer(t *testing.T) {
+	var o testObserver
+	d := Time(&o, func() { time.Sleep(time.Millisecond) })
+	assert.Equal(t, len(o), 1)
+	assert.Equal(t, o[0], d.Seconds())
+	assert.True(t, d >= time.Millisecond)
+
+	// the observer may be chosen when stopping
+	var o2 testObserver
+	timer := NewTimer(nil)
+	assert.True(t, timer.ObserveDuration() > 0)
+	timer.ObserveDurationTo(&o2)
+	assert.Equal(t, len(o2), 1)
+
+	set := NewSet()
+	h := set.NewFixedHistogram("hist", []float64{60})
+	func() {
+		defer h.Start().ObserveDuration()
+	}()
+	assertMarshal(t, set, []string{
+		`hist_bucket{le="60"} 1`,
+		`hist_bucket{le="+Inf"} 1`,
+		`hist_sum ` + formatFloat(h.sum()),
+		`hist_count 1`,
+	})
+}
+
+func formatFloat(v float64) string {
+	var b bytes.Buffer
+	writeFloat64(&b, v)
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// windowSlots is the number of sub-histograms a [WindowedHistogram] is
+// split into. The window slides forward in steps of window/windowSlots.
+const windowSlots = 6
+
+// NewWindowedHistogram creates a new WindowedHistogram on the global Set.
+// See [Set.NewWindowedHistogram].
+func NewWindowedHistogram(family string, window time.Duration, buckets []float64, tags ...string) *WindowedHistogram {
+	return defaultSet.NewWindowedHistogram(family, window, buckets, tags...)
+}
+
+// NewWindowedHistogram creates and returns new WindowedHistogram in s with
+// the given name, exposing only observations from the last window.
+//
+// family must be a Prometheus compatible identifier format.
+//
+// Optional tags must be specified in [label, value] pairs, for instance,
+//
+//	NewWindowedHistogram("family", time.Minute, []float64{0.1, 0.5, 1}, "label1", "value1")
+//
+// The returned WindowedHistogram is safe to use from concurrent goroutines.
+//
+// This will panic if values are invalid or already registered.
+func (s *Set) NewWindowedHistogram(family string, window time.Duration, buckets []float64, tags ...string) *WindowedHistogram {
+	h := newWindowedHistogram(window, buckets)
+	s.mustStoreMetric(h, MetricName{
+		Family: MustIdent(family),
+		Tags:   MustTags(tags...),
+	})
+	return h
+}
+
+// WindowedHistogram is a Prometheus-like histogram with fixed buckets that
+// only exposes observations made within a sliding window of time, making it
+// a gauge histogram: counts and sums may go down as old observations fall
+// out of the window.
+//
+// The window is made up of a ring of sub-histograms which are rotated as
+// time passes, so the exposed observations cover between 5/6 of the window
+// and the full window. Time is tracked with a coarse clock with 1 second
+// granularity, so windows shorter than a few seconds are not useful.
+//
+// Observations racing with the rotation of a sub-histogram may be lost.
+type WindowedHistogram struct {
+	buckets []float64
+	labels  []string
+	slot    time.Duration
+	slots   [windowSlots]windowSlot
+}
+
+type windowSlot struct {
+	// epoch is the number of slot durations since process start
+	// that the histogram is tracking.
+	epoch atomic.Int64
+	h     FixedHistogram
+}
+
+func newWindowedHistogram(window time.Duration, buckets []float64) *WindowedHistogram {
+	if window <= 0 {
+		panic("metrics: window must be positive")
+	}
+	buckets = getBuckets(buckets)
+	h := &WindowedHistogram{
+		buckets: buckets,
+		labels:  labelsForBuckets(buckets),
+		slot:    max(window/windowSlots, 1),
+	}
+	// start with every slot outside of the window
+	epoch := h.epoch()
+	for i := range h.slots {
+		h.slots[i].epoch.Store(epoch - windowSlots)
+		h.slots[i].h = FixedHistogram{
+			buckets:      buckets,
+			labels:       h.labels,
+			observations: make([]atomic.Uint64, len(buckets)),
+		}
+	}
+	return h
+}
+
+// Update updates h with val.
+//
+// NaNs are ignored.
+func (h *WindowedHistogram) Update(val float64) {
+	epoch := h.epoch()
+	slot := &h.slots[h.slotIndex(epoch)]
+	if old := slot.epoch.Load(); old != epoch && slot.epoch.CompareAndSwap(old, epoch) {
+		slot.h.Reset()
+	}
+	slot.h.Update(val)
+}
+
+// Observe updates h with val, identical to [WindowedHistogram.Update].
+//
+// NaNs are ignored.
+func (h *WindowedHistogram) Observe(val float64) {
+	h.Update(val)
+}
+
+// UpdateDuration updates request duration based on the given startTime.
+func (h *WindowedHistogram) UpdateDuration(startTime time.Time) {
+	h.Update(time.Since(startTime).Seconds())
+}
+
+// Snapshot returns a copy of the observations within the current window.
+func (h *WindowedHistogram) Snapshot() FixedHistogramSnapshot {
+	s := FixedHistogramSnapshot{
+		Buckets: h.buckets,
+		Counts:  make([]uint64, len(h.buckets)),
+	}
+	s.Inf, s.Sum, s.Count = h.collect(s.Counts)
+	return s
+}
+
+// Quantile estimates the q-quantile of the observations within the
+// current window. See [FixedHistogramSnapshot.Quantile].
+func (h *WindowedHistogram) Quantile(q float64) float64 {
+	return h.Snapshot().Quantile(q)
+}
+
+func (h *WindowedHistogram) epoch() int64 {
+	return int64(fastClock().Now()) / int64(h.slot)
+}
+
+func (h *WindowedHistogram) slotIndex(epoch int64) int64 {
+	// Instants may be negative, so ensure we wrap around positively
+	return (epoch%windowSlots + windowSlots) % windowSlots
+}
+
+// collect sums up all sub-histograms within the current window into counts.
+func (h *WindowedHistogram) collect(counts []uint64) (upper uint64, sum float64, count uint64) {
+	epoch := h.epoch()
+	for i := range h.slots {
+		slot := &h.slots[i]
+		if epoch-slot.epoch.Load() >= windowSlots {
+			continue
+		}
+		for j := range counts {
+			counts[j] += slot.h.observations[j].Load()
+		}
+		upper += slot.h.upper.Load()
+		sum += slot.h.sum()
+		count += slot.h.count.Load()
+	}
+	return upper, sum, count
+}
+
+func (h *WindowedHistogram) marshalTo(w ExpfmtWriter, name MetricName) {
+	// avoid allocating for reasonably sized bucket layouts
+	var stack [32]uint64
+	var counts []uint64
+	if len(h.buckets) > len(stack) {
+		counts = make([]uint64, len(h.buckets))
+	} else {
+		counts = stack[:len(h.buckets)]
+	}
+
+	upper, sum, count := h.collect(counts)
+	writeFixedHistogram(
+		w, name, h.labels,
+		func(i int) uint64 { return counts[i] },
+		upper, sum, count,
+	)
+}
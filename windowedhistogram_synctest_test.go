@@ -0,0 +1,100 @@
+//go:build goexperiment.synctest
+
+package metrics
+
+import (
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"go.withmatt.com/metrics/internal/assert"
+	"go.withmatt.com/metrics/internal/fasttime"
+)
+
+func TestWindowedHistogramRotation(t *testing.T) {
+	synctest.Run(func() {
+		testClock := fasttime.NewClock(time.Millisecond)
+		defer testClock.Stop()
+		stubFastClock(t, testClock)
+
+		set := NewSet()
+		h := set.NewWindowedHistogram("hist", 6*time.Second, []float64{1, 10})
+		h.Update(0.5)
+
+		time.Sleep(3 * time.Second)
+		h.Update(5)
+
+		assertMarshal(t, set, []string{
+			`hist_bucket{le="1"} 1`,
+			`hist_bucket{le="10"} 2`,
+			`hist_bucket{le="+Inf"} 2`,
+			`hist_sum 5.5`,
+			`hist_count 2`,
+		})
+
+		// the first observation falls out of the window
+		time.Sleep(4 * time.Second)
+		assertMarshal(t, set, []string{
+			`hist_bucket{le="1"} 0`,
+			`hist_bucket{le="10"} 1`,
+			`hist_bucket{le="+Inf"} 1`,
+			`hist_sum 5`,
+			`hist_count 1`,
+		})
+
+		time.Sleep(time.Minute)
+		assertMarshal(t, set, []string{
+			`hist_bucket{le="1"} 0`,
+			`hist_bucket{le="10"} 0`,
+			`hist_bucket{le="+Inf"} 0`,
+			`hist_sum 0`,
+			`hist_count 0`,
+		})
+
+		// slots are reused once rotated
+		h.Update(50)
+		assert.Equal(t, h.Snapshot().Count, 1)
+	})
+}
+
+func TestDecayingHistogramDecay(t *testing.T) {
+	synctest.Run(func() {
+		testClock := fasttime.NewClock(time.Millisecond)
+		defer testClock.Stop()
+		stubFastClock(t, testClock)
+
+		set := NewSet()
+		h := set.NewDecayingHistogram("hist", time.Minute, []float64{1, 10})
+		h.Update(0.5)
+		h.Update(5)
+		h.Update(50)
+
+		assertMarshal(t, set, []string{
+			`hist_bucket{le="1"} 1`,
+			`hist_bucket{le="10"} 2`,
+			`hist_bucket{le="+Inf"} 3`,
+			`hist_sum 55.5`,
+			`hist_count 3`,
+		})
+
+		time.Sleep(time.Minute)
+		h.Update(5)
+
+		assertMarshal(t, set, []string{
+			`hist_bucket{le="1"} 0.5`,
+			`hist_bucket{le="10"} 2`,
+			`hist_bucket{le="+Inf"} 2.5`,
+			`hist_sum 32.75`,
+			`hist_count 2.5`,
+		})
+
+		h.Reset()
+		assertMarshal(t, set, []string{
+			`hist_bucket{le="1"} 0`,
+			`hist_bucket{le="10"} 0`,
+			`hist_bucket{le="+Inf"} 0`,
+			`hist_sum 0`,
+			`hist_count 0`,
+		})
+	})
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"go.withmatt.com/metrics/internal/assert"
+)
+
+func TestWindowedHistogramNew(t *testing.T) {
+	NewSet().NewWindowedHistogram("foo", time.Minute, nil)
+	NewSet().NewWindowedHistogram("foo", time.Minute, nil, "bar", "baz")
+
+	// invalid label pairs
+	assert.Panics(t, func() { NewSet().NewWindowedHistogram("foo", time.Minute, nil, "bar") })
+
+	// invalid window
+	assert.Panics(t, func() { NewSet().NewWindowedHistogram("foo", 0, nil) })
+
+	// duplicate
+	set := NewSet()
+	set.NewWindowedHistogram("foo", time.Minute, nil)
+	assert.Panics(t, func() { set.NewWindowedHistogram("foo", time.Minute, nil) })
+}
+
+func TestWindowedHistogramSerial(t *testing.T) {
+	set := NewSet()
+	h := set.NewWindowedHistogram("hist", time.Hour, []float64{1, 10}, "a", "b")
+	h.Update(0.5)
+	h.Update(5)
+	h.Update(50)
+
+	assertMarshal(t, set, []string{
+		`hist_bucket{le="1",a="b"} 1`,
+		`hist_bucket{le="10",a="b"} 2`,
+		`hist_bucket{le="+Inf",a="b"} 3`,
+		`hist_sum{a="b"} 55.5`,
+		`hist_count{a="b"} 3`,
+	})
+	assert.Equal(t, h.Quantile(0.5), 5.5)
+}
+
+func TestDecayingHistogramNew(t *testing.T) {
+	NewSet().NewDecayingHistogram("foo", time.Minute, nil)
+	NewSet().NewDecayingHistogram("foo", time.Minute, nil, "bar", "baz")
+
+	// invalid label pairs
+	assert.Panics(t, func() { NewSet().NewDecayingHistogram("foo", time.Minute, nil, "bar") })
+
+	// invalid half-life
+	assert.Panics(t, func() { NewSet().NewDecayingHistogram("foo", 0, nil) })
+
+	// duplicate
+	set := NewSet()
+	set.NewDecayingHistogram("foo", time.Minute, nil)
+	assert.Panics(t, func() { set.NewDecayingHistogram("foo", time.Minute, nil) })
+}